@@ -2,6 +2,7 @@ package alias
 
 var aliases = map[string]string{
 	".jpg": ".jpeg",
+	".tif": ".tiff",
 }
 
 func Resolve(s string) string {
@@ -10,3 +11,13 @@ func Resolve(s string) string {
 	}
 	return s
 }
+
+// All returns a copy of the alias table, mapping each alias to the
+// extension it stands for.
+func All() map[string]string {
+	all := make(map[string]string, len(aliases))
+	for k, v := range aliases {
+		all[k] = v
+	}
+	return all
+}
@@ -0,0 +1,382 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/renja-g/convert/internal/alias"
+	"github.com/renja-g/convert/internal/converter"
+	"github.com/spf13/pflag"
+)
+
+// batchInput is a file selected for a batch conversion.
+type batchInput struct {
+	path string
+	// base is the directory the file was found under; its path relative
+	// to base is recreated below --out-dir.
+	base string
+	// explicit is set for files named on the command line, as opposed to
+	// files found through a glob or a directory.
+	explicit bool
+	// counter numbers the inputs in order, starting at 1.
+	counter int
+}
+
+// batchSummary counts the outcome of a batch conversion.
+type batchSummary struct {
+	converted, failed, skipped int
+}
+
+// batchMode reports whether the command line asks for a batch conversion
+// rather than converting a single file.
+func batchMode(args []string, outDir string, recursive bool) bool {
+	if len(args) > 1 || outDir != "" || recursive {
+		return true
+	}
+	for _, arg := range args {
+		if arg == stdio {
+			continue
+		}
+		if isGlob(arg) {
+			return true
+		}
+		if info, err := os.Stat(arg); err == nil && info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+func isGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// globBase returns the leading directories of pattern that contain no
+// glob meta characters.
+func globBase(pattern string) string {
+	dir := filepath.Dir(pattern)
+	for isGlob(dir) {
+		dir = filepath.Dir(dir)
+	}
+	return dir
+}
+
+// batchFlags returns the union of the flags of every converter to the
+// target format, so that options can be given for any input in the batch.
+func batchFlags(to string) *pflag.FlagSet {
+	converters := converter.GetConvertersTo(to)
+	sources := make([]string, 0, len(converters))
+	for from := range converters {
+		sources = append(sources, from)
+	}
+	sort.Strings(sources)
+
+	flags := pflag.NewFlagSet("batch", pflag.ExitOnError)
+	for _, from := range sources {
+		converters[from].GetFlags().VisitAll(func(f *pflag.Flag) {
+			if flags.Lookup(f.Name) == nil {
+				flags.AddFlag(f)
+			}
+		})
+	}
+	return flags
+}
+
+// expandInputs resolves the command line arguments to the files to convert.
+// Directories are walked when recursive is set and skipped otherwise.
+func expandInputs(args []string, recursive bool, skip func(path, reason string)) ([]batchInput, error) {
+	var inputs []batchInput
+	seen := make(map[string]bool)
+	add := func(in batchInput) {
+		if clean := filepath.Clean(in.path); !seen[clean] {
+			seen[clean] = true
+			in.counter = len(inputs) + 1
+			inputs = append(inputs, in)
+		}
+	}
+
+	for _, arg := range args {
+		if arg == stdio {
+			return nil, fmt.Errorf("stdin (%q) cannot be combined with other inputs", stdio)
+		}
+
+		paths := []string{arg}
+		base := filepath.Dir(arg)
+		explicit := true
+		if isGlob(arg) {
+			matches, err := filepath.Glob(arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match %q", arg)
+			}
+			paths, base, explicit = matches, globBase(arg), false
+		}
+
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				add(batchInput{path: path, base: base, explicit: explicit})
+				continue
+			}
+			if !recursive {
+				skip(path, "is a directory (use --recursive)")
+				continue
+			}
+			err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.Type().IsRegular() {
+					add(batchInput{path: p, base: path})
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return inputs, nil
+}
+
+// batchOutputPath returns where in is written, before conflicts are
+// resolved. Without an output directory the result is placed next to the
+// input.
+func batchOutputPath(in batchInput, outDir, to string) string {
+	if outDir == "" {
+		return converter.DefaultOutputPath(in.path, to)
+	}
+	rel, err := filepath.Rel(in.base, in.path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = filepath.Base(in.path)
+	}
+	rel = strings.TrimSuffix(rel, filepath.Ext(rel)) + to
+	return filepath.Join(outDir, rel)
+}
+
+// convertInput converts a single input of a batch. Batch inputs are
+// converted concurrently, so apart from outputs, which is safe for
+// concurrent use, it must not modify shared state.
+func convertInput(ctx context.Context, in batchInput, target string, names *namer, outputs *converter.Outputs, options converter.Options) fileResult {
+	res := fileResult{input: in}
+	// Warnings are reported together with the file they belong to.
+	options = options.WithWarningFunc(func(msg string) {
+		res.warnings = append(res.warnings, msg)
+	})
+
+	src, err := detectInput(in.path)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	res.mimeType, res.from = src.mimeType, src.from
+	if res.err = checkType(src, options); res.err != nil {
+		return res
+	}
+	if alias.Resolve(src.from) == target {
+		res.skipped = "already " + target
+		return res
+	}
+	c, found := converter.GetConverter(src.from, target)
+	if !found {
+		if in.explicit {
+			res.err = fmt.Errorf("%w from %s to %s", converter.ErrNoConverter, src.from, target)
+		} else {
+			res.skipped = fmt.Sprintf("no converter from %s", src.from)
+		}
+		return res
+	}
+	res.route = converter.Route(c)
+
+	outputFile, err := names.outputPath(in.path, batchOutputPath(in, outDir, target), src.from, target, in.counter, options)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	outputFile, err = outputs.Resolve(in.path, outputFile)
+	if err != nil {
+		if errors.Is(err, converter.ErrOutputExists) && outputs.Policy == converter.NoClobber {
+			res.skipped = err.Error()
+		} else {
+			res.err = conflictError(outputs, err)
+		}
+		return res
+	}
+	res.output = outputFile
+	if err := os.MkdirAll(filepath.Dir(outputFile), 0o755); err != nil {
+		res.err = err
+		return res
+	}
+	res.bytesIn = fileSize(in.path)
+	if res.err = c.Convert(ctx, in.path, outputFile, options); res.err == nil {
+		res.bytesOut = fileSize(outputFile)
+	}
+	return res
+}
+
+// runJobs runs convert on the inputs with at most jobs conversions in
+// flight and passes the results to report in input order. No further
+// conversions are started once ctx is done or, with failFast, after one has
+// failed; the inputs that were never started are returned.
+func runJobs(ctx context.Context, inputs []batchInput, jobs int, failFast bool, convert func(batchInput) fileResult, report func(fileResult)) []batchInput {
+	results := make([]chan fileResult, len(inputs))
+	for i := range results {
+		results[i] = make(chan fileResult, 1)
+	}
+
+	var failed atomic.Bool
+	go func() {
+		sem := make(chan struct{}, jobs)
+		for i, in := range inputs {
+			sem <- struct{}{}
+			if ctx.Err() != nil || (failFast && failed.Load()) {
+				<-sem
+				close(results[i])
+				continue
+			}
+			go func() {
+				defer func() { <-sem }()
+				res := convert(in)
+				if res.err != nil {
+					failed.Store(true)
+				}
+				results[i] <- res
+			}()
+		}
+	}()
+
+	var notStarted []batchInput
+	for i, ch := range results {
+		res, ok := <-ch
+		if !ok {
+			notStarted = append(notStarted, inputs[i])
+			continue
+		}
+		report(res)
+	}
+	return notStarted
+}
+
+// runBatch converts every input to the target format and prints a summary,
+// or a JSON record per input with --format json. It returns an error if any
+// conversion failed.
+func runBatch(ctx context.Context, args []string) error {
+	if to == "" {
+		return fmt.Errorf("a target format is required for batch conversions, use --to")
+	}
+	if output != "" {
+		return fmt.Errorf("--output cannot be used with multiple inputs, use --out-dir")
+	}
+	if jobs < 0 {
+		return fmt.Errorf("--jobs must not be negative, got %d", jobs)
+	}
+	target := normalizeFormat(to)
+
+	options, err := conversionOptions()
+	if err != nil {
+		return err
+	}
+
+	var summary batchSummary
+	// failureCode is the exit code shared by all failures, or exitFailure
+	// if they differ.
+	failureCode := exitOK
+	report := func(res fileResult) {
+		path := res.input.path
+		switch {
+		case res.skipped != "":
+			summary.skipped++
+		case res.err != nil:
+			summary.failed++
+			res.err = cancelError(res.err)
+			if code := exitCode(res.err); failureCode == exitOK {
+				failureCode = code
+			} else if code != failureCode {
+				failureCode = exitFailure
+			}
+		default:
+			summary.converted++
+		}
+
+		if jsonOutput() {
+			printJSON(res.record())
+			return
+		}
+		switch {
+		case res.skipped != "":
+			fmt.Printf("Skipped %s: %s\n", path, res.skipped)
+		case res.err != nil:
+			fmt.Printf("Failed %s: %v\n", path, res.err)
+		case explain:
+			fmt.Printf("Converted %s (%s)\n", path, strings.Join(res.route, " -> "))
+		default:
+			fmt.Printf("Converted %s\n", path)
+		}
+		for _, w := range res.warnings {
+			fmt.Fprintf(os.Stderr, "warning: %s: %s\n", path, w)
+		}
+	}
+	skip := func(path, reason string) {
+		report(fileResult{input: batchInput{path: path}, skipped: reason})
+	}
+
+	inputs, err := expandInputs(args, recursive, skip)
+	if err != nil {
+		return err
+	}
+
+	names, err := newNamer()
+	if err != nil {
+		return err
+	}
+	outputs := newOutputs()
+	workers := jobs
+	if workers == 0 {
+		workers = runtime.NumCPU()
+	}
+	convert := func(in batchInput) fileResult {
+		start := time.Now()
+		res := convertInput(ctx, in, target, names, outputs, options)
+		res.duration = time.Since(start)
+		return res
+	}
+	notStarted := runJobs(ctx, inputs, workers, failFast, convert, report)
+
+	if jsonOutput() {
+		for _, in := range notStarted {
+			printJSON(fileResult{input: in, skipped: "not started"}.record())
+		}
+	} else {
+		fmt.Printf("\n%d converted, %d failed, %d skipped", summary.converted, summary.failed, summary.skipped)
+		if len(notStarted) > 0 {
+			fmt.Printf(", %d not started", len(notStarted))
+		}
+		fmt.Println()
+	}
+
+	if err := ctx.Err(); err != nil {
+		return cancelError(err)
+	}
+	if summary.failed > 0 {
+		code := failureCode
+		if summary.converted > 0 {
+			code = exitPartial
+		}
+		return &exitError{code: code, err: fmt.Errorf("%d of %d files failed to convert", summary.failed, summary.converted+summary.failed)}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testInputs(n int) []batchInput {
+	inputs := make([]batchInput, n)
+	for i := range inputs {
+		inputs[i] = batchInput{path: fmt.Sprintf("in%d.png", i+1), counter: i + 1}
+	}
+	return inputs
+}
+
+func TestRunJobsReportsInInputOrder(t *testing.T) {
+	inputs := testInputs(20)
+	var running, maxRunning atomic.Int32
+	convert := func(in batchInput) fileResult {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		// Later inputs finish first.
+		time.Sleep(time.Duration(len(inputs)-in.counter) * time.Millisecond)
+		return fileResult{input: in}
+	}
+
+	var reported []int
+	notStarted := runJobs(context.Background(), inputs, 4, false, convert, func(res fileResult) {
+		reported = append(reported, res.input.counter)
+	})
+
+	if len(notStarted) != 0 {
+		t.Errorf("%d inputs not started, want 0", len(notStarted))
+	}
+	if len(reported) != len(inputs) {
+		t.Fatalf("reported %d results, want %d", len(reported), len(inputs))
+	}
+	for i, counter := range reported {
+		if counter != i+1 {
+			t.Fatalf("result %d is input %d, want results in input order: %v", i, counter, reported)
+		}
+	}
+	if m := maxRunning.Load(); m > 4 {
+		t.Errorf("%d conversions ran at once, want at most 4", m)
+	}
+}
+
+func TestRunJobsFailFast(t *testing.T) {
+	inputs := testInputs(5)
+	var started []int
+	convert := func(in batchInput) fileResult {
+		started = append(started, in.counter)
+		if in.counter == 2 {
+			return fileResult{input: in, err: errors.New("broken")}
+		}
+		return fileResult{input: in}
+	}
+
+	var reported []int
+	notStarted := runJobs(context.Background(), inputs, 1, true, convert, func(res fileResult) {
+		reported = append(reported, res.input.counter)
+	})
+
+	if fmt.Sprint(started) != "[1 2]" {
+		t.Errorf("started %v, want [1 2]", started)
+	}
+	if fmt.Sprint(reported) != "[1 2]" {
+		t.Errorf("reported %v, want [1 2]", reported)
+	}
+	var skipped []int
+	for _, in := range notStarted {
+		skipped = append(skipped, in.counter)
+	}
+	if fmt.Sprint(skipped) != "[3 4 5]" {
+		t.Errorf("not started %v, want [3 4 5]", skipped)
+	}
+}
+
+func TestRunJobsFailFastConcurrent(t *testing.T) {
+	inputs := testInputs(50)
+	convert := func(in batchInput) fileResult {
+		time.Sleep(time.Millisecond)
+		if in.counter == 3 {
+			return fileResult{input: in, err: errors.New("broken")}
+		}
+		return fileResult{input: in}
+	}
+
+	seen := make(map[int]int)
+	notStarted := runJobs(context.Background(), inputs, 4, true, convert, func(res fileResult) {
+		seen[res.input.counter]++
+	})
+	for _, in := range notStarted {
+		seen[in.counter]++
+	}
+
+	if len(notStarted) == 0 {
+		t.Error("all inputs were started after a failure")
+	}
+	for _, in := range inputs {
+		if seen[in.counter] != 1 {
+			t.Errorf("input %d was reported %d times, want once", in.counter, seen[in.counter])
+		}
+	}
+}
+
+func TestRunJobsCancelled(t *testing.T) {
+	inputs := testInputs(10)
+	ctx, cancel := context.WithCancel(context.Background())
+	convert := func(in batchInput) fileResult {
+		if in.counter == 2 {
+			cancel()
+		}
+		return fileResult{input: in}
+	}
+
+	var reported []int
+	notStarted := runJobs(ctx, inputs, 1, false, convert, func(res fileResult) {
+		reported = append(reported, res.input.counter)
+	})
+
+	if fmt.Sprint(reported) != "[1 2]" {
+		t.Errorf("reported %v, want [1 2]", reported)
+	}
+	if len(notStarted) != 8 || notStarted[0].counter != 3 {
+		t.Errorf("not started %d inputs from %v, want 8 from input 3", len(notStarted), notStarted)
+	}
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+
+	"github.com/renja-g/convert/internal/converter"
+)
+
+// Exit codes. They are part of the command line interface and listed in
+// the root command's help, so existing values must not change.
+const (
+	exitOK          = 0
+	exitFailure     = 1 // invalid usage and errors not covered below
+	exitUnsupported = 2 // the input format or the conversion is not supported
+	exitDecode      = 3 // the input could not be decoded
+	exitIO          = 4 // reading the input or writing the output failed
+	exitPartial     = 5 // some files of a batch could not be converted
+	exitCancelled   = 6 // interrupted or --timeout exceeded
+	exitLimit       = 7 // the input exceeds a resource limit
+)
+
+// exitError is an error that carries its exit code.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// exitCode returns the exit code for err.
+func exitCode(err error) int {
+	var exitErr *exitError
+	var pathErr *fs.PathError
+	var linkErr *os.LinkError
+	var syscallErr *os.SyscallError
+	var decodeErr *converter.DecodeError
+	var limitErr *converter.LimitError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &exitErr):
+		return exitErr.code
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return exitCancelled
+	case errors.As(err, &limitErr):
+		return exitLimit
+	case errors.As(err, &pathErr), errors.As(err, &linkErr), errors.As(err, &syscallErr):
+		return exitIO
+	case errors.Is(err, converter.ErrNoConverter):
+		return exitUnsupported
+	case errors.As(err, &decodeErr):
+		return exitDecode
+	}
+	return exitFailure
+}
+
+// errorKind names the class of err for machine-readable output.
+func errorKind(err error) string {
+	switch exitCode(err) {
+	case exitOK:
+		return ""
+	case exitUnsupported:
+		return "unsupported"
+	case exitDecode:
+		return "decode"
+	case exitIO:
+		return "io"
+	case exitCancelled:
+		return "cancelled"
+	case exitLimit:
+		return "limit"
+	}
+	return "error"
+}
@@ -0,0 +1,277 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/renja-g/convert/internal/alias"
+	"github.com/renja-g/convert/internal/converter"
+	"github.com/renja-g/convert/internal/detect"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var formatsMermaid bool
+
+var formatsCmd = &cobra.Command{
+	Use:   "formats",
+	Short: "List the supported formats and conversions",
+	Long: `List the supported formats and conversions, the format aliases, the
+detected MIME types and the options of each conversion.
+
+With --format json they are printed as one JSON object. With --mermaid
+the graph of direct conversions shown in the README is printed instead.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkOutputFormat(); err != nil {
+			return err
+		}
+		switch {
+		case formatsMermaid && cmd.Flags().Changed("format"):
+			return fmt.Errorf("--mermaid and --format cannot be used together")
+		case formatsMermaid:
+			printFormatsMermaid()
+		case jsonOutput():
+			printJSON(formatsJSON())
+		default:
+			printFormatsTable()
+		}
+		return nil
+	},
+}
+
+// formatsRecord is the JSON form of the capability matrix.
+type formatsRecord struct {
+	Formats     []string           `json:"formats"`
+	Conversions []formatConversion `json:"conversions"`
+	Aliases     map[string]string  `json:"aliases"`
+	MimeTypes   map[string]string  `json:"mime_types"`
+}
+
+type formatConversion struct {
+	From   string       `json:"from"`
+	To     string       `json:"to"`
+	Route  []string     `json:"route"`
+	Direct bool         `json:"direct"`
+	Flags  []flagRecord `json:"flags"`
+}
+
+type flagRecord struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Default string `json:"default"`
+	Usage   string `json:"usage"`
+}
+
+// canonicalFormats returns the registered formats without aliases.
+func canonicalFormats() []string {
+	var formats []string
+	for _, format := range converter.Formats() {
+		if alias.Resolve(format) == format {
+			formats = append(formats, format)
+		}
+	}
+	return formats
+}
+
+// conversions returns every conversion between canonical formats, ordered
+// by source and destination.
+func conversions() []formatConversion {
+	var list []formatConversion
+	for _, from := range canonicalFormats() {
+		converters := converter.GetConvertersFor(from)
+		targets := make([]string, 0, len(converters))
+		for to := range converters {
+			if alias.Resolve(to) == to {
+				targets = append(targets, to)
+			}
+		}
+		sort.Strings(targets)
+
+		for _, to := range targets {
+			c := converters[to]
+			route := converter.Route(c)
+			conv := formatConversion{From: from, To: to, Route: route, Direct: len(route) == 2, Flags: []flagRecord{}}
+			c.GetFlags().VisitAll(func(f *pflag.Flag) {
+				conv.Flags = append(conv.Flags, flagRecord{
+					Name:    f.Name,
+					Type:    f.Value.Type(),
+					Default: f.DefValue,
+					Usage:   f.Usage,
+				})
+			})
+			list = append(list, conv)
+		}
+	}
+	return list
+}
+
+// mimeTypes returns the detected MIME types of the formats that can be
+// converted.
+func mimeTypes() map[string]string {
+	types := detect.MimeTypes()
+	for t, ext := range types {
+		if !converter.IsRaster(ext) {
+			delete(types, t)
+		}
+	}
+	return types
+}
+
+func formatsJSON() formatsRecord {
+	return formatsRecord{
+		Formats:     canonicalFormats(),
+		Conversions: conversions(),
+		Aliases:     alias.All(),
+		MimeTypes:   mimeTypes(),
+	}
+}
+
+func printFormatsTable() {
+	formats := canonicalFormats()
+	byPair := make(map[[2]string]formatConversion)
+	list := conversions()
+	for _, c := range list {
+		byPair[[2]string{c.From, c.To}] = c
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Conversions (rows: from, columns: to):")
+	fmt.Fprintln(w)
+	header := []string{"FROM"}
+	for _, to := range formats {
+		header = append(header, strings.ToUpper(strings.TrimPrefix(to, ".")))
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, from := range formats {
+		row := []string{strings.TrimPrefix(from, ".")}
+		for _, to := range formats {
+			c, ok := byPair[[2]string{from, to}]
+			switch {
+			case !ok:
+				row = append(row, "-")
+			case c.Direct:
+				row = append(row, "yes")
+			default:
+				via := make([]string, 0, len(c.Route)-2)
+				for _, f := range c.Route[1 : len(c.Route)-1] {
+					via = append(via, strings.TrimPrefix(f, "."))
+				}
+				row = append(row, "via "+strings.Join(via, ","))
+			}
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Aliases:")
+	aliases := alias.All()
+	names := make([]string, 0, len(aliases))
+	for name := range aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\t%s\n", name, aliases[name])
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "MIME types:")
+	mimeTypes := mimeTypes()
+	types := make([]string, 0, len(mimeTypes))
+	for t := range mimeTypes {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		fmt.Fprintf(w, "  %s\t%s\n", t, mimeTypes[t])
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Options:")
+	for _, c := range list {
+		flags := make([]string, 0, len(c.Flags))
+		for _, f := range c.Flags {
+			flags = append(flags, "--"+f.Name)
+		}
+		if len(flags) == 0 {
+			flags = append(flags, "(none)")
+		}
+		fmt.Fprintf(w, "  %s -> %s\t%s\n", strings.TrimPrefix(c.From, "."), strings.TrimPrefix(c.To, "."), strings.Join(flags, ", "))
+	}
+	w.Flush()
+}
+
+// formatLinks are the pages that the nodes of the Mermaid graph link to.
+var formatLinks = map[string]string{
+	".gif":  "https://en.wikipedia.org/wiki/GIF",
+	".jpeg": "https://en.wikipedia.org/wiki/JPEG",
+	".png":  "https://en.wikipedia.org/wiki/Portable_Network_Graphics",
+	".webp": "https://en.wikipedia.org/wiki/WebP",
+}
+
+// printFormatsMermaid prints the graph of direct conversions, drawing a
+// single double-headed edge for conversions in both directions, and links
+// the formats to their description.
+func printFormatsMermaid() {
+	node := func(format string) string {
+		return strings.ToUpper(strings.TrimPrefix(format, "."))
+	}
+
+	formats := canonicalFormats()
+	fmt.Println("graph LR;")
+	fmt.Println("    subgraph Raster")
+	for _, f := range formats {
+		if converter.IsRaster(f) {
+			fmt.Printf("        %s;\n", node(f))
+		}
+	}
+	fmt.Println("    end")
+	for _, f := range formats {
+		if !converter.IsRaster(f) {
+			fmt.Printf("    %s;\n", node(f))
+		}
+	}
+	fmt.Println()
+
+	direct := make(map[[2]string]bool)
+	for _, c := range conversions() {
+		if c.Direct {
+			direct[[2]string{c.From, c.To}] = true
+		}
+	}
+	for i, a := range formats {
+		for _, b := range formats[i+1:] {
+			forward, backward := direct[[2]string{a, b}], direct[[2]string{b, a}]
+			switch {
+			case forward && backward:
+				fmt.Printf("    %s <--> %s;\n", node(a), node(b))
+			case forward:
+				fmt.Printf("    %s --> %s;\n", node(a), node(b))
+			case backward:
+				fmt.Printf("    %s --> %s;\n", node(b), node(a))
+			}
+		}
+	}
+
+	first := true
+	for _, f := range formats {
+		link, ok := formatLinks[f]
+		if !ok {
+			continue
+		}
+		if first {
+			fmt.Println()
+			first = false
+		}
+		fmt.Printf("    click %s %q %q\n", node(f), link, node(f)+" Details")
+	}
+}
+
+func init() {
+	formatsCmd.Flags().BoolVar(&formatsMermaid, "mermaid", false, "Print the graph of direct conversions as a Mermaid diagram")
+	rootCmd.AddCommand(formatsCmd)
+}
@@ -0,0 +1,288 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"io"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/renja-g/convert/internal/converter"
+	"github.com/renja-g/convert/internal/exif"
+	"github.com/renja-g/convert/internal/icc"
+	"github.com/spf13/cobra"
+)
+
+var infoCmd = &cobra.Command{
+	Use:   "info [file... | -]",
+	Short: "Show the format, dimensions and metadata of files",
+	Long: `Show what files contain: the detected type and whether it matches the
+file extension, the dimensions, colour model, bit depth, alpha channel and
+number of frames of images, their colour profile, a summary of their EXIF
+metadata and the file size. With --format json one record is printed per file.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkOutputFormat(); err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+
+		var failed int
+		var lastErr error
+		for i, path := range args {
+			rec, err := inspect(path)
+			if err != nil {
+				failed++
+				lastErr = err
+				rec.Error, rec.ErrorKind = err.Error(), errorKind(err)
+			}
+			if jsonOutput() {
+				printJSON(rec)
+				continue
+			}
+			if i > 0 {
+				fmt.Println()
+			}
+			printInfo(rec)
+		}
+
+		switch {
+		case failed == 0:
+			return nil
+		case len(args) == 1:
+			return lastErr
+		case failed < len(args):
+			return &exitError{code: exitPartial, err: fmt.Errorf("%d of %d files could not be read", failed, len(args))}
+		}
+		return &exitError{code: exitCode(lastErr), err: fmt.Errorf("none of the %d files could be read", len(args))}
+	},
+}
+
+// infoRecord describes a file for the info command. The image fields are
+// only set for formats that can be decoded.
+type infoRecord struct {
+	Input    string `json:"input"`
+	Size     int64  `json:"size"`
+	MimeType string `json:"mime_type,omitempty"`
+	Format   string `json:"format,omitempty"`
+	// Confidence is how certain the detection of Format is, or "none" if
+	// it is taken from the file extension.
+	Confidence string `json:"confidence,omitempty"`
+	// Extension is the file name extension, which may not match Format.
+	Extension         string `json:"extension,omitempty"`
+	ExtensionMismatch bool   `json:"extension_mismatch"`
+	Width             int    `json:"width,omitempty"`
+	Height            int    `json:"height,omitempty"`
+	ColorModel        string `json:"color_model,omitempty"`
+	BitDepth          int    `json:"bit_depth,omitempty"`
+	Alpha             bool   `json:"alpha"`
+	Frames            int    `json:"frames,omitempty"`
+	// ColorProfile is the description of the embedded ICC profile.
+	ColorProfile string            `json:"color_profile,omitempty"`
+	EXIF         map[string]string `json:"exif,omitempty"`
+	Warnings     []string          `json:"warnings,omitempty"`
+	Error        string            `json:"error,omitempty"`
+	ErrorKind    string            `json:"error_kind,omitempty"`
+
+	// exif keeps the EXIF summary in display order.
+	exif []exif.Field
+}
+
+// inspect reads what the info command reports about the file at path, or
+// about stdin for "-".
+func inspect(path string) (infoRecord, error) {
+	rec := infoRecord{Input: path}
+	src, err := detectInput(path)
+	if err != nil {
+		return rec, err
+	}
+	rec.MimeType, rec.Format, rec.Confidence = src.mimeType, src.from, src.confidence.String()
+
+	// The image is read several times, so stdin is kept in memory.
+	var r io.ReadSeeker
+	if path == stdio {
+		data, err := io.ReadAll(src.reader)
+		if err != nil {
+			return rec, err
+		}
+		r, rec.Size = bytes.NewReader(data), int64(len(data))
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return rec, err
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return rec, err
+		}
+		r, rec.Size = f, info.Size()
+
+		rec.Extension = filepath.Ext(path)
+		rec.ExtensionMismatch = src.mismatch != nil
+	}
+	if !converter.IsRaster(src.from) {
+		return rec, nil
+	}
+
+	rewind := func() (io.Reader, error) {
+		_, err := r.Seek(0, io.SeekStart)
+		return r, err
+	}
+	decodeErr := func(err error) error {
+		return &converter.DecodeError{Format: src.from, Err: err}
+	}
+
+	if r, err := rewind(); err != nil {
+		return rec, err
+	} else if config, err := converter.DecodeConfig(r, src.from); err != nil {
+		return rec, decodeErr(err)
+	} else {
+		rec.Width, rec.Height = config.Width, config.Height
+		rec.ColorModel, rec.BitDepth, rec.Alpha = describeColorModel(config.ColorModel)
+	}
+
+	if r, err := rewind(); err != nil {
+		return rec, err
+	} else if rec.Frames, err = converter.CountFrames(r, src.from); err != nil {
+		return rec, decodeErr(err)
+	}
+
+	if _, err := rewind(); err != nil {
+		return rec, err
+	}
+	md, err := converter.DecodeMetadata(r, src.from)
+	if err != nil {
+		return rec, decodeErr(err)
+	}
+	if md.ICC != nil {
+		if p, err := icc.Parse(md.ICC); err != nil {
+			rec.Warnings = append(rec.Warnings, fmt.Sprintf("ignoring invalid ICC profile: %v", err))
+		} else if rec.ColorProfile = p.Description; rec.ColorProfile == "" {
+			rec.ColorProfile = "unnamed " + p.ColorSpace + " profile"
+		}
+	}
+	if md.EXIF != nil {
+		x, err := exif.Parse(md.EXIF)
+		if err != nil {
+			rec.Warnings = append(rec.Warnings, fmt.Sprintf("ignoring invalid EXIF metadata: %v", err))
+			return rec, nil
+		}
+		rec.exif = x.Summary()
+		rec.EXIF = make(map[string]string, len(rec.exif))
+		for _, f := range rec.exif {
+			rec.EXIF[strings.ReplaceAll(strings.ToLower(f.Name), " ", "_")] = f.Value
+		}
+	}
+	return rec, nil
+}
+
+// describeColorModel names the colour model a decoder reported and returns
+// its bits per channel, or per index for palettes, and whether it has an
+// alpha channel. Decoders report color.RGBAModel for opaque true colour
+// images and color.NRGBAModel for those with alpha.
+func describeColorModel(m color.Model) (name string, depth int, alpha bool) {
+	if p, ok := m.(color.Palette); ok {
+		// GIF files may only have a palette per frame.
+		if len(p) == 0 {
+			return "paletted", 0, false
+		}
+		for _, c := range p {
+			if _, _, _, a := c.RGBA(); a != 0xffff {
+				alpha = true
+				break
+			}
+		}
+		return fmt.Sprintf("paletted (%d colours)", len(p)), max(bits.Len(uint(len(p)-1)), 1), alpha
+	}
+
+	switch m {
+	case color.RGBAModel:
+		return "RGB", 8, false
+	case color.RGBA64Model:
+		return "RGB", 16, false
+	case color.NRGBAModel:
+		return "RGBA", 8, true
+	case color.NRGBA64Model:
+		return "RGBA", 16, true
+	case color.GrayModel:
+		return "gray", 8, false
+	case color.Gray16Model:
+		return "gray", 16, false
+	case color.YCbCrModel:
+		return "YCbCr", 8, false
+	case color.CMYKModel:
+		return "CMYK", 8, false
+	}
+	return "unknown", 0, false
+}
+
+// formatSize formats a file size for people.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, prefix := float64(n)/unit, 0
+	for value >= unit && prefix < 3 {
+		value /= unit
+		prefix++
+	}
+	return fmt.Sprintf("%.1f %ciB (%d bytes)", value, "KMGT"[prefix], n)
+}
+
+func printInfo(rec infoRecord) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, rec.Input)
+	switch {
+	case rec.MimeType != "":
+		fmt.Fprintf(w, "  Type:\t%s (%s, %s confidence)\n", rec.MimeType, rec.Format, rec.Confidence)
+	case rec.Format != "":
+		fmt.Fprintf(w, "  Type:\t%s (assumed)\n", rec.Format)
+	}
+	if rec.ExtensionMismatch {
+		fmt.Fprintf(w, "  Extension:\t%s does not match the content\n", rec.Extension)
+	}
+	if rec.Error != "" {
+		fmt.Fprintf(w, "  Error:\t%s\n", rec.Error)
+		return
+	}
+	fmt.Fprintf(w, "  Size:\t%s\n", formatSize(rec.Size))
+	if rec.Width == 0 {
+		fmt.Fprintln(w, "  Format not supported, no image details")
+		return
+	}
+
+	fmt.Fprintf(w, "  Dimensions:\t%dx%d\n", rec.Width, rec.Height)
+	colour := rec.ColorModel
+	if rec.BitDepth > 0 {
+		colour += fmt.Sprintf(", %d-bit", rec.BitDepth)
+	}
+	if rec.Alpha {
+		colour += ", alpha"
+	}
+	fmt.Fprintf(w, "  Colour:\t%s\n", colour)
+	if rec.ColorProfile != "" {
+		fmt.Fprintf(w, "  Profile:\t%s\n", rec.ColorProfile)
+	}
+	fmt.Fprintf(w, "  Frames:\t%d\n", rec.Frames)
+	for _, msg := range rec.Warnings {
+		fmt.Fprintf(w, "  Warning:\t%s\n", msg)
+	}
+	if len(rec.exif) > 0 {
+		fmt.Fprintln(w, "  EXIF:")
+		for _, f := range rec.exif {
+			fmt.Fprintf(w, "    %s:\t%s\n", f.Name, f.Value)
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(infoCmd)
+}
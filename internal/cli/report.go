@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	formatText = "text"
+	formatJSON = "json"
+)
+
+// jsonOutput reports whether --format json was given. Machine-readable
+// records are then the only thing written to stdout.
+func jsonOutput() bool {
+	return outputFormat == formatJSON
+}
+
+// checkOutputFormat validates the --format flag.
+func checkOutputFormat() error {
+	if outputFormat != formatText && outputFormat != formatJSON {
+		return fmt.Errorf("unknown --format %q, want %s or %s", outputFormat, formatText, formatJSON)
+	}
+	return nil
+}
+
+// fileResult is the outcome of converting one input file.
+type fileResult struct {
+	input    batchInput
+	mimeType string
+	from     string
+	output   string
+	route    []string
+	warnings []string
+	// skipped is the reason the input was not converted, if it was skipped.
+	skipped  string
+	err      error
+	bytesIn  int64
+	bytesOut int64
+	duration time.Duration
+}
+
+// fileRecord is the JSON form of a fileResult.
+type fileRecord struct {
+	Input      string   `json:"input"`
+	MimeType   string   `json:"mime_type,omitempty"`
+	From       string   `json:"from,omitempty"`
+	Output     string   `json:"output,omitempty"`
+	Route      []string `json:"route,omitempty"`
+	Status     string   `json:"status"`
+	Reason     string   `json:"reason,omitempty"`
+	BytesIn    int64    `json:"bytes_in"`
+	BytesOut   int64    `json:"bytes_out"`
+	DurationMS float64  `json:"duration_ms"`
+	Warnings   []string `json:"warnings,omitempty"`
+	Error      string   `json:"error,omitempty"`
+	ErrorKind  string   `json:"error_kind,omitempty"`
+}
+
+func (r fileResult) record() fileRecord {
+	rec := fileRecord{
+		Input:      r.input.path,
+		MimeType:   r.mimeType,
+		From:       r.from,
+		Output:     r.output,
+		Route:      r.route,
+		Status:     "converted",
+		BytesIn:    r.bytesIn,
+		BytesOut:   r.bytesOut,
+		DurationMS: float64(r.duration.Microseconds()) / 1000,
+		Warnings:   r.warnings,
+	}
+	switch {
+	case r.skipped != "":
+		rec.Status = "skipped"
+		rec.Reason = r.skipped
+	case r.err != nil:
+		rec.Status = "failed"
+		rec.Error = r.err.Error()
+		rec.ErrorKind = errorKind(r.err)
+	}
+	return rec
+}
+
+// conversionsRecord is the JSON form of the conversions listed for an input
+// when no target format is given.
+type conversionsRecord struct {
+	Input       string             `json:"input"`
+	MimeType    string             `json:"mime_type"`
+	From        string             `json:"from"`
+	Conversions []conversionRecord `json:"conversions"`
+}
+
+type conversionRecord struct {
+	To    string   `json:"to"`
+	Route []string `json:"route"`
+}
+
+// printJSON writes v to stdout as a single line of JSON.
+func printJSON(v interface{}) {
+	json.NewEncoder(os.Stdout).Encode(v)
+}
+
+// fileSize returns the size of the file at path, or 0 if it can't be read.
+func fileSize(path string) int64 {
+	if info, err := os.Stat(path); err == nil {
+		return info.Size()
+	}
+	return 0
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
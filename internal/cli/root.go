@@ -1,10 +1,16 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/renja-g/convert/internal/alias"
 	"github.com/renja-g/convert/internal/converter"
@@ -14,76 +20,540 @@ import (
 	"github.com/renja-g/convert/internal/detect"
 	"github.com/renja-g/convert/internal/tui"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
+// stdio is the file name that stands for stdin (input) or stdout (output).
+const stdio = "-"
+
 var output string
 var to string
+var explain bool
+var outDir string
+var recursive bool
+var jobs int
+var failFast bool
+var timeout time.Duration
+var overwrite bool
+var noClobber bool
+var suffixOnConflict bool
+var nameTemplate string
+var outputFormat string
+var strictType bool
+var assumeType string
+var limits converter.Limits
+
+// source and converterFlags are filled in by registerConverterFlags before
+// cobra parses the command line, so that the selected converter's flags are
+// known to the root command.
+var (
+	source         *inputSource
+	converterFlags *pflag.FlagSet
+)
+
+// inputSource describes the detected format of the input file.
+type inputSource struct {
+	path     string
+	mimeType string
+	from     string
+	// confidence is how certain the detection of from is; it is
+	// detect.None if from is the file extension or --assume-type.
+	confidence detect.Confidence
+	// mismatch is set if the file extension names another type than the
+	// content.
+	mismatch *detect.Mismatch
+	// reader replaces os.Stdin when reading from "-", because sniffing
+	// consumes the first bytes of the stream.
+	reader io.Reader
+}
 
 var rootCmd = &cobra.Command{
-	Use:   "convert [input file]",
+	Use:   "convert [input file... | -]",
 	Short: "A universal file converter",
-	Long:  `A universal file converter that supports various file formats.`,
-	Args:  cobra.RangeArgs(0, 1),
+	Long: `A universal file converter that supports various file formats.
+
+Exit codes:
+  0  success
+  1  invalid usage or an error not listed below
+  2  unsupported input format or conversion
+  3  the input could not be decoded
+  4  reading the input or writing the output failed
+  5  some files of a batch could not be converted
+  6  interrupted or --timeout exceeded
+  7  the input exceeds --max-pixels, --max-input-bytes or --max-frames`,
+	Args:          cobra.ArbitraryArgs,
+	SilenceErrors: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) == 0 {
-			return tui.Run()
+			// The UI cannot ask before replacing a file, so unless a
+			// conflict flag says otherwise the result gets a free name.
+			policy := converter.SuffixOnConflict
+			if overwrite || noClobber {
+				policy = newOutputs().Policy
+			}
+			return tui.Run(policy)
 		}
+		if err := checkOutputFormat(); err != nil {
+			return err
+		}
+		// The command line is valid, so errors from here on are not
+		// usage errors.
+		cmd.SilenceUsage = true
 
-		inputFile := args[0]
-
-		mimeType, err := detect.MimeType(inputFile)
-		if err != nil {
-			return fmt.Errorf("could not detect mime type: %w", err)
+		ctx := cmd.Context()
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
 		}
 
-		from, ok := detect.ExtensionFromMimeType(mimeType)
-		if !ok {
-			// fallback to extension
-			from = strings.ToLower(filepath.Ext(inputFile))
+		if batchMode(args, outDir, recursive) {
+			return runBatch(ctx, args)
 		}
+		if to == "" {
+			return listConversions(args[0])
+		}
+		return runSingle(ctx, args[0])
+	},
+}
 
-		if to != "" {
-			// User has specified a target format.
-			// The `to` flag should not include a dot, but our registry uses it.
-			if !strings.HasPrefix(to, ".") {
-				to = "." + to
-			}
+// lookupInput returns the detected input, reusing the detection done by
+// registerConverterFlags.
+func lookupInput(inputFile string) (*inputSource, error) {
+	if source != nil && source.path == inputFile {
+		return source, nil
+	}
+	return detectInput(inputFile)
+}
 
-			to = alias.Resolve(to)
+// listConversions prints the formats the input can be converted to.
+func listConversions(inputFile string) error {
+	src, err := lookupInput(inputFile)
+	if err != nil {
+		return err
+	}
+	converters := converter.GetConvertersFor(src.from)
+	if len(converters) == 0 {
+		return fmt.Errorf("%w for %s", converter.ErrNoConverter, src.from)
+	}
 
-			c, found := converter.GetConverter(from, to)
-			if !found {
-				return fmt.Errorf("no converter found from %s to %s", from, to)
-			}
+	targets := make([]string, 0, len(converters))
+	for to_format := range converters {
+		targets = append(targets, to_format)
+	}
+	sort.Strings(targets)
 
-			fmt.Printf("Converting %s to %s...\n", inputFile, to)
-			return c.Convert(inputFile, output, nil)
+	if jsonOutput() {
+		rec := conversionsRecord{Input: inputFile, MimeType: src.mimeType, From: src.from}
+		for _, to_format := range targets {
+			rec.Conversions = append(rec.Conversions, conversionRecord{
+				To:    to_format,
+				Route: converter.Route(converters[to_format]),
+			})
+		}
+		printJSON(rec)
+		return nil
+	}
+
+	fmt.Printf("Available conversions for %s:\n", src.from)
+	for _, to_format := range targets {
+		if explain {
+			fmt.Printf("- %s (%s)\n", to_format, strings.Join(converter.Route(converters[to_format]), " -> "))
 		} else {
-			// User has not specified a target format.
-			// List available conversions.
-			converters := converter.GetConvertersFor(from)
-			if len(converters) == 0 {
-				return fmt.Errorf("no converters found for %s", from)
-			}
+			fmt.Printf("- %s\n", to_format)
+		}
+	}
+	fmt.Println("\nPlease specify a target format with the --to flag.")
+	return nil
+}
+
+// runSingle converts one input file, or stdin, to the --to format. With
+// --format json the result is printed as a record.
+func runSingle(ctx context.Context, inputFile string) error {
+	res := fileResult{input: batchInput{path: inputFile}}
+	start := time.Now()
+	err := convertSingle(ctx, inputFile, &res)
+	res.duration = time.Since(start)
+
+	if jsonOutput() {
+		res.err = err
+		printJSON(res.record())
+	}
+	return err
+}
+
+func convertSingle(ctx context.Context, inputFile string, res *fileResult) error {
+	src, err := lookupInput(inputFile)
+	if err != nil {
+		return err
+	}
+	res.mimeType, res.from = src.mimeType, src.from
+
+	target := normalizeFormat(to)
+	c, found := converter.GetConverter(src.from, target)
+	if !found {
+		return fmt.Errorf("%w from %s to %s", converter.ErrNoConverter, src.from, target)
+	}
+	res.route = converter.Route(c)
+
+	options, err := conversionOptions()
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		options = options.WithWarningFunc(func(msg string) {
+			res.warnings = append(res.warnings, msg)
+		})
+	}
+	if err := checkType(src, options); err != nil {
+		return err
+	}
+
+	// Keep stdout clean for the converted data when it is piped, and
+	// for the record with --format json.
+	toStdout := output == stdio || (inputFile == stdio && output == "" && nameTemplate == "")
+	var status io.Writer = os.Stdout
+	switch {
+	case toStdout && jsonOutput():
+		return fmt.Errorf("--format %s cannot be used when writing to stdout", formatJSON)
+	case jsonOutput():
+		status = io.Discard
+	case toStdout:
+		status = os.Stderr
+	}
+
+	if explain {
+		fmt.Fprintf(status, "Conversion path: %s\n", strings.Join(res.route, " -> "))
+	}
+
+	outputFile := stdio
+	if !toStdout {
+		names, err := newNamer()
+		if err != nil {
+			return err
+		}
 
-			fmt.Printf("Available conversions for %s:\n", from)
-			for to_format := range converters {
-				fmt.Printf("- %s\n", to_format)
+		outputFile = output
+		if outputFile == "" {
+			def := converter.DefaultOutputPath(inputFile, c.To())
+			if inputFile == stdio {
+				def = "stdin" + c.To()
+			}
+			if outputFile, err = names.outputPath(inputFile, def, src.from, c.To(), 1, options); err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(outputFile), 0o755); err != nil {
+				return err
 			}
-			fmt.Println("\nPlease specify a target format with the --to flag.")
+		}
+
+		outputs := newOutputs()
+		resolved, err := outputs.Resolve(inputFile, outputFile)
+		if errors.Is(err, converter.ErrOutputExists) && outputs.Policy == converter.NoClobber {
+			res.skipped = err.Error()
+			fmt.Fprintf(status, "Skipped %s: %v\n", inputFile, err)
 			return nil
 		}
-	},
+		if err != nil {
+			return conflictError(outputs, err)
+		}
+		outputFile = resolved
+	}
+	res.output = outputFile
+
+	fmt.Fprintf(status, "Converting %s to %s...\n", inputFile, target)
+	res.bytesIn, res.bytesOut, err = runConversion(ctx, c, inputFile, src.reader, outputFile, options)
+	return cancelError(err)
+}
+
+// detectInput sniffs the format of inputFile, or of stdin for "-". With
+// --assume-type the given format is used instead.
+func detectInput(inputFile string) (*inputSource, error) {
+	src := &inputSource{path: inputFile}
+	if assumeType != "" {
+		src.from = normalizeFormat(assumeType)
+		if inputFile == stdio {
+			src.reader = os.Stdin
+		}
+		return src, nil
+	}
+
+	var match detect.Match
+	var err error
+	if inputFile == stdio {
+		match, src.reader, err = detect.DetectReader(os.Stdin)
+	} else {
+		match, err = detect.DetectFile(inputFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not detect mime type: %w", err)
+	}
+	src.mimeType, src.from, src.confidence = match.MimeType, match.Ext, match.Confidence
+
+	if src.from == "" {
+		if inputFile == stdio {
+			return nil, &exitError{
+				code: exitUnsupported,
+				err:  fmt.Errorf("could not detect input format from stdin (%s)", src.mimeType),
+			}
+		}
+		// fallback to extension
+		src.from = strings.ToLower(filepath.Ext(inputFile))
+		src.confidence = detect.None
+	} else if inputFile != stdio {
+		src.mismatch = detect.CheckExtension(inputFile, match)
+	}
+	return src, nil
+}
+
+// checkType reports an input whose extension does not match its content,
+// as a warning or, with --strict-type, as an error. With --strict-type
+// inputs whose content is not recognised are refused as well.
+func checkType(src *inputSource, options converter.Options) error {
+	if assumeType != "" {
+		return nil
+	}
+	switch {
+	case strictType && src.mismatch != nil:
+		return &exitError{code: exitUnsupported, err: src.mismatch}
+	case strictType && src.confidence == detect.None:
+		return &exitError{code: exitUnsupported, err: errors.New("the content type could not be detected")}
+	case src.mismatch != nil:
+		options.Warn("%v, converting it as %s", src.mismatch, src.from)
+	}
+	return nil
+}
+
+// normalizeFormat turns a user supplied format such as "jpg" into the
+// registry's extension form (".jpeg").
+func normalizeFormat(format string) string {
+	// The `to` flag should not include a dot, but our registry uses it.
+	if !strings.HasPrefix(format, ".") {
+		format = "." + format
+	}
+	return alias.Resolve(strings.ToLower(format))
+}
+
+// registerConverterFlags looks ahead at the command line for the input file
+// and --to, and adds the selected converter's flags to the root command so
+// that cobra parses them and lists them in --help. Errors are ignored here;
+// they are reported properly once the command runs.
+func registerConverterFlags(args []string) {
+	fs := pflag.NewFlagSet("lookahead", pflag.ContinueOnError)
+	fs.ParseErrorsWhitelist.UnknownFlags = true
+	fs.SetOutput(io.Discard)
+	fs.Usage = func() {}
+	// Mirror the root command's flags without sharing their values, so
+	// that flag arguments are not mistaken for the input file.
+	mirror := func(f *pflag.Flag) {
+		if fs.Lookup(f.Name) == nil {
+			flag := fs.VarPF(&lookaheadValue{typ: f.Value.Type()}, f.Name, f.Shorthand, "")
+			flag.NoOptDefVal = f.NoOptDefVal
+		}
+	}
+	rootCmd.PersistentFlags().VisitAll(mirror)
+	rootCmd.Flags().VisitAll(mirror)
+	fs.BoolP("help", "h", false, "")
+	if err := fs.Parse(args); err != nil || fs.NArg() == 0 {
+		return
+	}
+
+	target := fs.Lookup("to").Value.String()
+	if target == "" {
+		return
+	}
+
+	if batchMode(fs.Args(), fs.Lookup("out-dir").Value.String(), fs.Lookup("recursive").Value.String() == "true") {
+		converterFlags = batchFlags(normalizeFormat(target))
+		rootCmd.Flags().AddFlagSet(converterFlags)
+		return
+	}
+
+	// Detection depends on --assume-type, which cobra sets to the same
+	// value once it parses the command line.
+	assumeType = fs.Lookup("assume-type").Value.String()
+	src, err := detectInput(fs.Arg(0))
+	if err != nil {
+		return
+	}
+	source = src
+
+	c, ok := converter.GetConverter(src.from, normalizeFormat(target))
+	if !ok {
+		return
+	}
+	converterFlags = c.GetFlags()
+	rootCmd.Flags().AddFlagSet(converterFlags)
+}
+
+// lookaheadValue is a pflag.Value that accepts anything, used to skip over
+// flags while looking ahead at the command line.
+type lookaheadValue struct {
+	typ   string
+	value string
+}
+
+func (v *lookaheadValue) String() string     { return v.value }
+func (v *lookaheadValue) Set(s string) error { v.value = s; return nil }
+func (v *lookaheadValue) Type() string       { return v.typ }
+
+// newOutputs returns an output path resolver for the policy selected
+// with the conflict flags.
+func newOutputs() *converter.Outputs {
+	policy := converter.FailOnConflict
+	switch {
+	case overwrite:
+		policy = converter.Overwrite
+	case noClobber:
+		policy = converter.NoClobber
+	case suffixOnConflict:
+		policy = converter.SuffixOnConflict
+	}
+	return &converter.Outputs{Policy: policy}
+}
+
+// namer derives output paths from the --name template.
+type namer struct {
+	template *converter.NameTemplate
+	start    time.Time
+}
+
+// newNamer parses the --name template. It returns nil if there is none.
+func newNamer() (*namer, error) {
+	if nameTemplate == "" {
+		return nil, nil
+	}
+	t, err := converter.ParseNameTemplate(nameTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return &namer{template: t, start: time.Now()}, nil
+}
+
+// outputPath returns the output path for the counter-th input of a run,
+// where def is the path used without a template.
+func (n *namer) outputPath(inputFile, def, from, to string, counter int, options converter.Options) (string, error) {
+	if n == nil {
+		return def, nil
+	}
+	return n.template.Expand(converter.NameSource{
+		Path:    inputFile,
+		From:    from,
+		To:      to,
+		Dir:     filepath.Dir(def),
+		Counter: counter,
+		Time:    n.start,
+		Options: options,
+	})
+}
+
+// conflictError adds a hint about the conflict flags to errors about
+// existing output files.
+func conflictError(outputs *converter.Outputs, err error) error {
+	if errors.Is(err, converter.ErrOutputExists) && outputs.Policy == converter.FailOnConflict {
+		return fmt.Errorf("%w (use --overwrite, --no-clobber or --suffix-on-conflict)", err)
+	}
+	return err
+}
+
+// cancelError replaces the error of a cancelled conversion with one that
+// names the cause.
+func cancelError(err error) error {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return &exitError{code: exitCancelled, err: fmt.Errorf("conversion timed out after %s", timeout)}
+	case errors.Is(err, context.Canceled):
+		return &exitError{code: exitCancelled, err: errors.New("conversion cancelled")}
+	}
+	return err
+}
+
+// conversionOptions returns the options of the selected converter and the
+// resource limits.
+func conversionOptions() (converter.Options, error) {
+	if limits.MaxPixels < 0 || limits.MaxInputBytes < 0 || limits.MaxFrames < 0 {
+		return nil, fmt.Errorf("--max-pixels, --max-input-bytes and --max-frames must not be negative")
+	}
+	var options converter.Options
+	if converterFlags != nil {
+		options = converter.OptionsFromFlags(converterFlags)
+	}
+	return options.WithLimits(limits), nil
+}
+
+// runConversion performs the conversion, wiring "-" to stdin and stdout,
+// and returns the number of bytes read and written.
+func runConversion(ctx context.Context, c converter.Converter, inputFile string, input io.Reader, outputFile string, options converter.Options) (int64, int64, error) {
+	if inputFile != stdio && outputFile != stdio {
+		if err := c.Convert(ctx, inputFile, outputFile, options); err != nil {
+			return 0, 0, err
+		}
+		return fileSize(inputFile), fileSize(outputFile), nil
+	}
+
+	if input == nil {
+		f, err := os.Open(inputFile)
+		if err != nil {
+			return 0, 0, err
+		}
+		defer f.Close()
+		input = f
+	}
+	in := &countingReader{r: input}
+
+	if outputFile == stdio {
+		out := &countingWriter{w: os.Stdout}
+		err := c.ConvertStream(ctx, in, out, options)
+		return in.n, out.n, err
+	}
+
+	f, err := converter.CreateAtomic(outputFile)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Abort()
+
+	out := &countingWriter{w: f}
+	if err := c.ConvertStream(ctx, in, out, options); err != nil {
+		return in.n, out.n, err
+	}
+	return in.n, out.n, f.Commit()
 }
 
 func init() {
-	rootCmd.PersistentFlags().StringVarP(&output, "output", "o", "", "Output file path")
+	rootCmd.PersistentFlags().StringVarP(&output, "output", "o", "", "Output file path (\"-\" for stdout)")
 	rootCmd.PersistentFlags().StringVarP(&to, "to", "t", "", "Target format (e.g., png, jpg)")
+	rootCmd.PersistentFlags().BoolVar(&explain, "explain", false, "Show the chain of formats used for the conversion")
+	rootCmd.PersistentFlags().StringVar(&outDir, "out-dir", "", "Directory for converted files, mirroring the input directories")
+	rootCmd.PersistentFlags().BoolVarP(&recursive, "recursive", "r", false, "Convert the files in input directories and their subdirectories")
+	rootCmd.PersistentFlags().IntVarP(&jobs, "jobs", "j", 0, "Number of files to convert in parallel (0 = one per CPU)")
+	rootCmd.PersistentFlags().BoolVar(&failFast, "fail-fast", false, "Stop starting new conversions after the first failure")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "Abort the conversion after this long, e.g. 30s (0 = no limit)")
+	rootCmd.PersistentFlags().BoolVar(&overwrite, "overwrite", false, "Replace existing output files")
+	rootCmd.PersistentFlags().BoolVar(&noClobber, "no-clobber", false, "Skip inputs whose output file already exists")
+	rootCmd.PersistentFlags().BoolVar(&suffixOnConflict, "suffix-on-conflict", false, "Write to a free name such as \"photo (1).webp\" if the output file exists")
+	rootCmd.MarkFlagsMutuallyExclusive("overwrite", "no-clobber", "suffix-on-conflict")
+	rootCmd.PersistentFlags().StringVar(&nameTemplate, "name", "", "Output file name template, e.g. \"{dir}/{stem}-{width}x{height}.{ext}\"")
+	rootCmd.MarkFlagsMutuallyExclusive("output", "name")
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "format", formatText, "Result output format: text or json")
+	rootCmd.PersistentFlags().BoolVar(&strictType, "strict-type", false, "Refuse inputs whose content does not match their extension or is not recognised")
+	rootCmd.PersistentFlags().StringVar(&assumeType, "assume-type", "", "Read inputs as this format instead of detecting it (e.g., png)")
+	rootCmd.MarkFlagsMutuallyExclusive("strict-type", "assume-type")
+	rootCmd.PersistentFlags().Int64Var(&limits.MaxPixels, "max-pixels", 0, "Refuse images with more pixels (width x height) than this (0 = no limit)")
+	rootCmd.PersistentFlags().Int64Var(&limits.MaxInputBytes, "max-input-bytes", 0, "Refuse inputs larger than this many bytes (0 = no limit)")
+	rootCmd.PersistentFlags().IntVar(&limits.MaxFrames, "max-frames", 0, "Refuse animations with more frames than this (0 = no limit)")
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	registerConverterFlags(os.Args[1:])
+
+	// Interrupting the program cancels the running conversions, which
+	// removes their partial output before exiting.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(exitCode(err))
 	}
 }
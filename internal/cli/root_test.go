@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/renja-g/convert/internal/converter"
+	"github.com/renja-g/convert/internal/detect"
+)
+
+// setTypeFlags sets --strict-type and --assume-type for the duration of
+// the test.
+func setTypeFlags(t *testing.T, strict bool, assume string) {
+	t.Helper()
+	oldStrict, oldAssume := strictType, assumeType
+	strictType, assumeType = strict, assume
+	t.Cleanup(func() { strictType, assumeType = oldStrict, oldAssume })
+}
+
+func TestCheckType(t *testing.T) {
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"image.png":   buf.Bytes(),
+		"image.jpg":   buf.Bytes(),
+		"unknown.png": {0x00, 0x01, 0x02, 0x03, 0xfe, 0xff},
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		file   string
+		strict bool
+		assume string
+		// from is the format the input is read as.
+		from string
+		// warning is part of the expected warning, if any.
+		warning string
+		// err is part of the expected error, if any.
+		err string
+	}{
+		{file: "image.png", from: ".png"},
+		{file: "image.png", strict: true, from: ".png"},
+		{file: "image.jpg", from: ".png", warning: "extension .jpg does not match the content (image/png), converting it as .png"},
+		{file: "image.jpg", strict: true, from: ".png", err: "extension .jpg does not match the content (image/png)"},
+		{file: "unknown.png", from: ".png"},
+		{file: "unknown.png", strict: true, from: ".png", err: "the content type could not be detected"},
+		// --assume-type skips detection and the checks.
+		{file: "image.jpg", assume: "gif", from: ".gif"},
+		{file: "unknown.png", assume: "jpg", from: ".jpeg"},
+	}
+	for _, tt := range tests {
+		name := tt.file
+		if tt.strict {
+			name += "/strict"
+		}
+		if tt.assume != "" {
+			name += "/assume=" + tt.assume
+		}
+		t.Run(name, func(t *testing.T) {
+			setTypeFlags(t, tt.strict, tt.assume)
+			src, err := detectInput(filepath.Join(dir, tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if src.from != tt.from {
+				t.Errorf("input read as %s, want %s", src.from, tt.from)
+			}
+
+			var warnings []string
+			options := converter.Options{}.WithWarningFunc(func(msg string) { warnings = append(warnings, msg) })
+			err = checkType(src, options)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("checkType = %v, want nil", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("checkType = %v, want an error containing %q", err, tt.err)
+			case tt.err != "":
+				var exitErr *exitError
+				if !errors.As(err, &exitErr) || exitErr.code != exitUnsupported {
+					t.Errorf("checkType = %#v, want exit code %d", err, exitUnsupported)
+				}
+			}
+
+			if tt.warning == "" && len(warnings) > 0 {
+				t.Errorf("unexpected warnings %q", warnings)
+			}
+			if tt.warning != "" && (len(warnings) != 1 || warnings[0] != tt.warning) {
+				t.Errorf("warnings %q, want %q", warnings, tt.warning)
+			}
+		})
+	}
+}
+
+func TestDetectInputMismatch(t *testing.T) {
+	setTypeFlags(t, false, "")
+	path := filepath.Join(t.TempDir(), "image.jpg")
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	src, err := detectInput(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src.confidence != detect.High || src.mimeType != "image/png" {
+		t.Errorf("detected %s with %v confidence, want image/png with high", src.mimeType, src.confidence)
+	}
+	if src.mismatch == nil || src.mismatch.Ext != ".jpg" {
+		t.Errorf("mismatch %v, want one for .jpg", src.mismatch)
+	}
+}
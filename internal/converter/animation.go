@@ -0,0 +1,118 @@
+package converter
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"time"
+)
+
+// Disposal specifies what happens to a frame's area once it has been shown.
+type Disposal int
+
+const (
+	// DisposeNone leaves the frame on the canvas.
+	DisposeNone Disposal = iota
+	// DisposeBackground clears the frame's area to transparent.
+	DisposeBackground
+	// DisposePrevious restores the canvas to its state before the frame.
+	DisposePrevious
+)
+
+// Frame is a single frame of an Animation.
+type Frame struct {
+	// Image holds the frame's pixels; its bounds give the frame's position
+	// on the canvas.
+	Image image.Image
+	// Delay is how long the frame is shown.
+	Delay time.Duration
+	// Disposal is applied after the frame has been shown.
+	Disposal Disposal
+	// Blend draws the frame over the canvas using its alpha channel;
+	// otherwise it replaces the pixels of its area.
+	Blend bool
+}
+
+// Animation is the format independent representation of an animated image,
+// used to convert between animated formats the way image.Image is used for
+// still images.
+type Animation struct {
+	// Width and Height are the size of the canvas.
+	Width, Height int
+	// LoopCount is the number of times the animation is played; 0 means forever.
+	LoopCount int
+	Frames    []Frame
+}
+
+// Render composites the frames and returns the full canvas as it is shown
+// for each frame.
+func (a *Animation) Render() []*image.NRGBA {
+	rendered := make([]*image.NRGBA, 0, len(a.Frames))
+	a.composite(len(a.Frames), func(canvas *image.NRGBA) {
+		rendered = append(rendered, cloneNRGBA(canvas))
+	})
+	return rendered
+}
+
+// Still returns the frame selected by the "frame" option, rendered onto the
+// full canvas, for conversion to a still image. Without the option the first
+// frame is used.
+func (a *Animation) Still(options Options) (image.Image, error) {
+	frame := options.Int("frame", -1)
+	if frame < 0 {
+		if len(a.Frames) > 1 {
+			options.Warn("converting only the first of %d frames (use --frame to pick another)", len(a.Frames))
+		}
+		frame = 0
+	}
+	if frame >= len(a.Frames) {
+		return nil, fmt.Errorf("frame %d out of range, the animation has %d frames", frame, len(a.Frames))
+	}
+	return a.composite(frame+1, nil), nil
+}
+
+// composite draws the first n frames onto a single canvas and returns it as
+// it is shown for the last of them. If shown is not nil it is called with
+// the canvas as it is shown for each frame; the canvas is reused, so shown
+// must copy it to keep it.
+func (a *Animation) composite(n int, shown func(canvas *image.NRGBA)) *image.NRGBA {
+	bounds := image.Rect(0, 0, a.Width, a.Height)
+	canvas := image.NewNRGBA(bounds)
+	var previous *image.NRGBA
+
+	for i, f := range a.Frames[:n] {
+		if f.Disposal == DisposePrevious && i < n-1 {
+			if previous == nil {
+				previous = image.NewNRGBA(bounds)
+			}
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		op := draw.Src
+		if f.Blend {
+			op = draw.Over
+		}
+		r := f.Image.Bounds()
+		draw.Draw(canvas, r, f.Image, r.Min, op)
+		if shown != nil {
+			shown(canvas)
+		}
+		if i == n-1 {
+			break
+		}
+
+		switch f.Disposal {
+		case DisposeBackground:
+			draw.Draw(canvas, r, image.Transparent, image.Point{}, draw.Src)
+		case DisposePrevious:
+			canvas, previous = previous, canvas
+		}
+	}
+	return canvas
+}
+
+func cloneNRGBA(m *image.NRGBA) *image.NRGBA {
+	c := image.NewNRGBA(m.Rect)
+	copy(c.Pix, m.Pix)
+	return c
+}
@@ -0,0 +1,62 @@
+package converter_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/renja-g/convert/internal/converter"
+)
+
+// square returns a w × h frame at (x, y) filled with c.
+func square(x, y, w, h int, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(x, y, x+w, y+h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return img
+}
+
+func TestAnimationStill(t *testing.T) {
+	red := color.NRGBA{255, 0, 0, 255}
+	green := color.NRGBA{0, 255, 0, 255}
+	blue := color.NRGBA{0, 0, 255, 128}
+	a := &converter.Animation{
+		Width:  8,
+		Height: 8,
+		Frames: []converter.Frame{
+			{Image: square(0, 0, 8, 8, red)},
+			{Image: square(2, 2, 4, 4, green), Disposal: converter.DisposePrevious},
+			{Image: square(4, 4, 4, 4, blue), Blend: true, Disposal: converter.DisposeBackground},
+			{Image: square(1, 1, 2, 2, green), Disposal: converter.DisposePrevious},
+			{Image: square(3, 3, 2, 2, blue), Blend: true},
+		},
+	}
+
+	rendered := a.Render()
+	if len(rendered) != len(a.Frames) {
+		t.Fatalf("Render returned %d frames, want %d", len(rendered), len(a.Frames))
+	}
+	// The second frame is disposed of again, so the third is drawn over
+	// the first.
+	if got := rendered[2].NRGBAAt(3, 3); got != red {
+		t.Errorf("frame 2 at (3, 3) is %v, want %v", got, red)
+	}
+	if got := rendered[3].NRGBAAt(5, 5); got.A != 0 {
+		t.Errorf("frame 3 at (5, 5) is %v, want transparent", got)
+	}
+
+	for i, want := range rendered {
+		got, err := a.Still(converter.Options{"frame": i})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.(*image.NRGBA).Pix, want.Pix) {
+			t.Errorf("Still of frame %d differs from Render", i)
+		}
+	}
+	if _, err := a.Still(converter.Options{"frame": len(a.Frames)}); err == nil {
+		t.Error("Still of a frame out of range succeeded")
+	}
+}
@@ -0,0 +1,110 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// AtomicFile is an output file that replaces its destination only once it
+// has been written completely. The data goes to a temporary file in the
+// destination directory, which Commit syncs and renames over the
+// destination and Abort removes. Until then, an existing file at the
+// destination is left untouched. Commit also syncs the directory, so that
+// the rename survives a crash.
+type AtomicFile struct {
+	*os.File
+	path string
+	done bool
+}
+
+// CreateAtomic starts writing the file at path. The result gets the
+// permissions of the file it replaces, or those of a regular new file
+// after the umask.
+func CreateAtomic(path string) (*AtomicFile, error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	info, statErr := os.Stat(path)
+	f, err := createTemp(dir, base)
+	if err != nil {
+		return nil, err
+	}
+	if statErr == nil {
+		if err := f.Chmod(info.Mode().Perm()); err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return nil, err
+		}
+	}
+	return &AtomicFile{File: f, path: path}, nil
+}
+
+// createTemp creates a new file next to base in dir. Unlike os.CreateTemp,
+// which makes private files, it asks for the mode of a regular new file so
+// that the umask applies.
+func createTemp(dir, base string) (*os.File, error) {
+	for try := 0; ; try++ {
+		name := filepath.Join(dir, fmt.Sprintf(".%s.%d.tmp", base, rand.Uint32()))
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o666)
+		if errors.Is(err, fs.ErrExist) && try < 100 {
+			continue
+		}
+		return f, err
+	}
+}
+
+// Commit flushes the written data to disk and moves it to the destination.
+// The temporary file is removed if that fails.
+func (f *AtomicFile) Commit() error {
+	if f.done {
+		return errors.New("atomic file already closed")
+	}
+	f.done = true
+
+	err := f.Sync()
+	if closeErr := f.File.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), f.path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return syncDir(filepath.Dir(f.path))
+}
+
+// syncDir flushes the entries of dir to disk. Windows cannot sync
+// directories and makes renames durable by itself.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if closeErr := d.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Abort discards the written data. It does nothing after Commit, so it
+// can be deferred.
+func (f *AtomicFile) Abort() {
+	if f.done {
+		return
+	}
+	f.done = true
+	f.File.Close()
+	os.Remove(f.Name())
+}
@@ -0,0 +1,77 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// chain is a Converter that runs several converters one after another,
+// passing intermediate results through in-memory buffers.
+type chain struct {
+	steps []Converter
+}
+
+func (c *chain) From() string { return c.steps[0].From() }
+func (c *chain) To() string   { return c.steps[len(c.steps)-1].To() }
+
+func (c *chain) Convert(ctx context.Context, inputPath, outputPath string, options Options) error {
+	return ConvertFile(ctx, c, inputPath, outputPath, options)
+}
+
+func (c *chain) ConvertStream(ctx context.Context, r io.Reader, w io.Writer, options Options) error {
+	src := r
+	stepOptions := options
+	for i, step := range c.steps {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		dst := w
+		var buf bytes.Buffer
+		if i < len(c.steps)-1 {
+			dst = &buf
+		}
+		if err := step.ConvertStream(ctx, src, dst, stepOptions); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("%s to %s: %w", step.From(), step.To(), err)
+		}
+		src = &buf
+		stepOptions = intermediateOptions(options)
+	}
+	return nil
+}
+
+// intermediateOptions returns the options for the steps after the first.
+// The input size limit applies to the input, not to the intermediate
+// results, and the transforms and the colour conversion have already been
+// applied by the first step.
+func intermediateOptions(o Options) Options {
+	limits := o.Limits()
+	limits.MaxInputBytes = 0
+	options := o.WithLimits(limits)
+	for _, name := range transformOptions {
+		delete(options, name)
+	}
+	options["auto-orient"] = false
+	options["color-profile"] = profileKeep
+	return options
+}
+
+func (c *chain) GetFlags() *pflag.FlagSet {
+	route := Route(c)
+	for i := range route {
+		route[i] = strings.TrimPrefix(route[i], ".")
+	}
+
+	flags := pflag.NewFlagSet(strings.Join(route, "-to-"), pflag.ExitOnError)
+	for _, step := range c.steps {
+		flags.AddFlagSet(step.GetFlags())
+	}
+	return flags
+}
@@ -0,0 +1,134 @@
+package converter
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+
+	"github.com/renja-g/convert/internal/icc"
+	"github.com/spf13/pflag"
+)
+
+// colorFlags returns the flags that control the colour profile of the
+// output.
+func colorFlags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("color", pflag.ExitOnError)
+	flags.String("color-profile", "srgb", "Convert the colours to this ICC profile: srgb, the path of an RGB profile, or keep to leave them and the input's profile as they are")
+	return flags
+}
+
+// Values of --color-profile besides file paths.
+const (
+	profileSRGB = "srgb"
+	profileKeep = "keep"
+)
+
+// colorConversion holds the --color-profile option.
+type colorConversion struct {
+	target *icc.Profile
+}
+
+// newColorConversion reads the --color-profile option. It returns nil if
+// the colours are kept.
+func newColorConversion(options Options) (*colorConversion, error) {
+	name := options.String("color-profile", profileSRGB)
+	switch name {
+	case profileSRGB:
+		return &colorConversion{target: icc.SRGB}, nil
+	case profileKeep:
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("--color-profile: %w", err)
+	}
+	p, err := icc.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("--color-profile %s: %w", name, err)
+	}
+	if !p.MatrixShaper() {
+		return nil, fmt.Errorf("--color-profile %s: only RGB matrix/TRC profiles are supported", name)
+	}
+	return &colorConversion{target: p}, nil
+}
+
+// transform returns the transform from the profile embedded in md to the
+// target, or nil if the colours don't need converting or can't be.
+// Images without a profile are taken to be sRGB. Profiles of other colour
+// spaces than RGB are kept without a warning when converting to sRGB, the
+// default.
+func (c *colorConversion) transform(md *Metadata, options Options) *icc.Transform {
+	if c == nil {
+		return nil
+	}
+	src := icc.SRGB
+	if md != nil && md.ICC != nil {
+		p, err := icc.Parse(md.ICC)
+		if err != nil {
+			options.Warn("not converting the colours: %v", err)
+			return nil
+		}
+		if !p.MatrixShaper() {
+			// Grey and CMYK images are left with their profile. That is
+			// only worth a warning if another profile was asked for.
+			if p.ColorSpace == "RGB" || c.target != icc.SRGB {
+				options.Warn("not converting the colours: the %s profile %q is not supported", p.ColorSpace, p.Description)
+			}
+			return nil
+		}
+		src = p
+	}
+	if src.Same(c.target) {
+		return nil
+	}
+	t, err := icc.NewTransform(src, c.target)
+	if err != nil {
+		options.Warn("not converting the colours: %v", err)
+		return nil
+	}
+	return t
+}
+
+// convertColors returns img with its colours converted by t. Images with
+// 16 bits per channel keep them.
+func convertColors(img image.Image, t *icc.Transform) image.Image {
+	b := img.Bounds()
+	if sixteenBit(img) {
+		m := image.NewNRGBA64(b)
+		draw.Draw(m, b, img, b.Min, draw.Src)
+		be := binary.BigEndian
+		for i := 0; i < len(m.Pix); i += 8 {
+			r, g, bl := t.Convert16(be.Uint16(m.Pix[i:]), be.Uint16(m.Pix[i+2:]), be.Uint16(m.Pix[i+4:]))
+			be.PutUint16(m.Pix[i:], r)
+			be.PutUint16(m.Pix[i+2:], g)
+			be.PutUint16(m.Pix[i+4:], bl)
+		}
+		return m
+	}
+	m := image.NewNRGBA(b)
+	draw.Draw(m, b, img, b.Min, draw.Src)
+	for i := 0; i < len(m.Pix); i += 4 {
+		m.Pix[i], m.Pix[i+1], m.Pix[i+2] = t.Convert(m.Pix[i], m.Pix[i+1], m.Pix[i+2])
+	}
+	return m
+}
+
+// sixteenBit reports whether img has 16 bits per channel.
+func sixteenBit(img image.Image) bool {
+	switch img.ColorModel() {
+	case color.RGBA64Model, color.NRGBA64Model, color.Gray16Model:
+		return true
+	}
+	return false
+}
+
+// convertAnimationColors converts the colours of every frame of a in place.
+func convertAnimationColors(a *Animation, t *icc.Transform) {
+	for i := range a.Frames {
+		a.Frames[i].Image = convertColors(a.Frames[i].Image, t)
+	}
+}
@@ -0,0 +1,55 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/renja-g/convert/internal/icc"
+)
+
+// emptyProfile returns an ICC profile of the given colour space without
+// any tags.
+func emptyProfile(colorSpace string) []byte {
+	data := make([]byte, 132)
+	copy(data[16:], colorSpace+"    "[len(colorSpace):])
+	copy(data[20:], "XYZ ")
+	copy(data[36:], "acsp")
+	return data
+}
+
+func TestColorTransformWarnings(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "srgb.icc")
+	if err := os.WriteFile(target, icc.SRGB.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		profile string
+		source  string
+		warn    bool
+	}{
+		{"gray to default", profileSRGB, "GRAY", false},
+		{"cmyk to default", profileSRGB, "CMYK", false},
+		{"unsupported rgb to default", profileSRGB, "RGB", true},
+		{"gray to requested", target, "GRAY", true},
+		{"cmyk to requested", target, "CMYK", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := newColorConversion(Options{"color-profile": tt.profile})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var warnings []string
+			options := Options{}.WithWarningFunc(func(msg string) { warnings = append(warnings, msg) })
+			if tr := c.transform(&Metadata{ICC: emptyProfile(tt.source)}, options); tr != nil {
+				t.Error("got a transform for an unsupported profile")
+			}
+			if warned := len(warnings) > 0; warned != tt.warn {
+				t.Errorf("warnings %q, want a warning: %t", warnings, tt.warn)
+			}
+		})
+	}
+}
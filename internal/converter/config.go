@@ -0,0 +1,77 @@
+package converter
+
+import (
+	"fmt"
+	"image"
+	"io"
+
+	"github.com/renja-g/convert/internal/alias"
+)
+
+// lookupDecoder returns the decoder for format, resolving aliases.
+func lookupDecoder(format string) (Decoder, error) {
+	d, ok := decoders[format]
+	if !ok {
+		d, ok = decoders[alias.Resolve(format)]
+	}
+	if !ok {
+		return nil, fmt.Errorf("no decoder found for %s", format)
+	}
+	return d, nil
+}
+
+// DecodeConfig returns the dimensions of an image in the given format. Only
+// the header is read if the format's decoder is a ConfigDecoder; otherwise
+// the whole image is decoded.
+func DecodeConfig(r io.Reader, format string) (image.Config, error) {
+	d, err := lookupDecoder(format)
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	if cd, ok := d.(ConfigDecoder); ok {
+		return cd.DecodeConfig(r)
+	}
+	img, err := d.Decode(r, nil)
+	if err != nil {
+		return image.Config{}, err
+	}
+	b := img.Bounds()
+	return image.Config{ColorModel: img.ColorModel(), Width: b.Dx(), Height: b.Dy()}, nil
+}
+
+// CountFrames returns the number of frames of an image in the given format,
+// which is 1 for still images. Animations are decoded in full unless the
+// format's decoder is a FrameCounter.
+func CountFrames(r io.Reader, format string) (int, error) {
+	d, err := lookupDecoder(format)
+	if err != nil {
+		return 0, err
+	}
+
+	switch d := d.(type) {
+	case FrameCounter:
+		return d.CountFrames(r)
+	case AnimationDecoder:
+		anim, err := d.DecodeAnimation(r, nil)
+		if err != nil {
+			return 0, err
+		}
+		return len(anim.Frames), nil
+	}
+	return 1, nil
+}
+
+// DecodeMetadata returns the metadata embedded in an image in the given
+// format. It is empty for formats whose decoder is not a MetadataDecoder.
+func DecodeMetadata(r io.Reader, format string) (*Metadata, error) {
+	d, err := lookupDecoder(format)
+	if err != nil {
+		return nil, err
+	}
+
+	if md, ok := d.(MetadataDecoder); ok {
+		return md.DecodeMetadata(r)
+	}
+	return &Metadata{}, nil
+}
@@ -0,0 +1,34 @@
+package converter
+
+import (
+	"context"
+	"io"
+)
+
+// contextReader fails reads once ctx is done, so that a cancelled
+// conversion stops at the next read instead of consuming all input.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// contextWriter fails writes once ctx is done, so that a cancelled
+// conversion stops writing output as soon as possible.
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (w contextWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return w.w.Write(p)
+}
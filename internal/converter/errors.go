@@ -0,0 +1,23 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNoConverter is returned if no chain of converters leads from the
+// source to the destination format.
+var ErrNoConverter = errors.New("no converter found")
+
+// DecodeError reports that the input could not be read in its source
+// format, e.g. because it is corrupt or not what its extension claims.
+type DecodeError struct {
+	Format string
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("decoding %s: %v", e.Format, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
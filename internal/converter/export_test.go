@@ -0,0 +1,7 @@
+package converter
+
+// NewChain lets the external tests, which can import the formats, build
+// chains between formats that have a direct converter.
+func NewChain(steps ...Converter) Converter {
+	return newConverter(steps)
+}
@@ -0,0 +1,80 @@
+package converter
+
+import "image"
+
+// EXIF orientations, which also describe the --rotate and --flip
+// operations.
+const (
+	orientNormal     = 1
+	orientFlipH      = 2
+	orientRotate180  = 3
+	orientFlipV      = 4
+	orientTranspose  = 5
+	orientRotate90   = 6
+	orientTransverse = 7
+	orientRotate270  = 8
+)
+
+// swapsAxes reports whether orientation o exchanges width and height.
+func swapsAxes(o int) bool {
+	return o >= orientTranspose && o <= orientRotate270
+}
+
+// orient rotates and flips img as EXIF orientation o says to display it.
+// Rotations are clockwise.
+func orient(img image.Image, o int) image.Image {
+	if o <= orientNormal || o > orientRotate270 {
+		return img
+	}
+	src := toWorking(img)
+	pix, stride, size := pixels(src)
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if swapsAxes(o) {
+		dw, dh = h, w
+	}
+
+	// source returns the source pixel shown at x, y of the result.
+	var source func(x, y int) (int, int)
+	switch o {
+	case orientFlipH:
+		source = func(x, y int) (int, int) { return w - 1 - x, y }
+	case orientRotate180:
+		source = func(x, y int) (int, int) { return w - 1 - x, h - 1 - y }
+	case orientFlipV:
+		source = func(x, y int) (int, int) { return x, h - 1 - y }
+	case orientTranspose:
+		source = func(x, y int) (int, int) { return y, x }
+	case orientRotate90:
+		source = func(x, y int) (int, int) { return y, h - 1 - x }
+	case orientTransverse:
+		source = func(x, y int) (int, int) { return w - 1 - y, h - 1 - x }
+	case orientRotate270:
+		source = func(x, y int) (int, int) { return w - 1 - y, x }
+	}
+
+	dst := newWorking(src, image.Rect(0, 0, dw, dh))
+	dstPix, dstStride, _ := pixels(dst)
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			sx, sy := source(x, y)
+			copy(dstPix[y*dstStride+x*size:][:size], pix[sy*stride+sx*size:])
+		}
+	}
+	return dst
+}
+
+// cropImage returns the part r of img, with r relative to the top left
+// corner of img and the result's bounds starting at 0, 0. The result has
+// the pixel format of toWorking(img).
+func cropImage(img image.Image, r image.Rectangle) image.Image {
+	src := toWorking(img)
+	pix, stride, size := pixels(src)
+	dst := newWorking(src, image.Rect(0, 0, r.Dx(), r.Dy()))
+	dstPix, dstStride, _ := pixels(dst)
+	for y := 0; y < r.Dy(); y++ {
+		copy(dstPix[y*dstStride:][:r.Dx()*size], pix[(r.Min.Y+y)*stride+r.Min.X*size:])
+	}
+	return dst
+}
@@ -0,0 +1,112 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// fill returns a w × h image of the given type filled with c.
+func fill(m image.Image, c color.Color) image.Image {
+	b := m.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			m.(interface{ Set(x, y int, c color.Color) }).Set(x, y, c)
+		}
+	}
+	return m
+}
+
+// TestTransformsKeepPrecision checks that orienting, cropping and
+// resampling keep images with more than 8 bits per channel and images that
+// are not premultiplied in their precision.
+func TestTransformsKeepPrecision(t *testing.T) {
+	r := image.Rect(0, 0, 12, 8)
+	tests := []struct {
+		name string
+		img  image.Image
+		// want is the pixel type of the result.
+		want image.Image
+	}{
+		{"nrgba", fill(image.NewNRGBA(r), color.NRGBA{10, 20, 30, 40}), &image.NRGBA{}},
+		{"nrgba64", fill(image.NewNRGBA64(r), color.NRGBA64{0x1234, 0x5678, 0x9abc, 0x8001}), &image.NRGBA64{}},
+		{"rgba64", fill(image.NewRGBA64(r), color.RGBA64{0x1234, 0x5678, 0x9abc, 0xffff}), &image.NRGBA64{}},
+		{"gray16", fill(image.NewGray16(r), color.Gray16{0x1234}), &image.NRGBA64{}},
+		{"rgba", fill(image.NewRGBA(r), color.RGBA{10, 20, 30, 40}), &image.RGBA{}},
+		{"gray", fill(image.NewGray(r), color.Gray{77}), &image.RGBA{}},
+	}
+	for _, tt := range tests {
+		want := color.NRGBA64Model.Convert(tt.img.At(0, 0)).(color.NRGBA64)
+		results := map[string]image.Image{
+			"orient":   orient(tt.img, orientRotate90),
+			"crop":     cropImage(tt.img, image.Rect(2, 1, 7, 6)),
+			"resample": resample(tt.img, 5, 3, filters["lanczos"]),
+			"enlarge":  resample(tt.img, 30, 17, filters["bilinear"]),
+		}
+		for op, img := range results {
+			if got, want := typeName(img), typeName(tt.want); got != want {
+				t.Errorf("%s/%s: result is %s, want %s", tt.name, op, got, want)
+			}
+			b := img.Bounds()
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					got := color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+					if !near(got, want, tolerance(tt.want)) {
+						t.Fatalf("%s/%s: pixel (%d, %d) is %v, want %v", tt.name, op, x, y, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func typeName(img image.Image) string {
+	switch img.(type) {
+	case *image.NRGBA:
+		return "*image.NRGBA"
+	case *image.NRGBA64:
+		return "*image.NRGBA64"
+	case *image.RGBA:
+		return "*image.RGBA"
+	}
+	return "another type"
+}
+
+// tolerance is the largest difference of a 16-bit channel that a result of
+// the type of img may have from its source. Premultiplied 8-bit colours of
+// translucent pixels lose precision.
+func tolerance(img image.Image) int {
+	switch img.(type) {
+	case *image.NRGBA64:
+		return 2
+	case *image.NRGBA:
+		return 0x101
+	}
+	return 0x1000
+}
+
+func near(a, b color.NRGBA64, tolerance int) bool {
+	for _, d := range []int{int(a.R) - int(b.R), int(a.G) - int(b.G), int(a.B) - int(b.B), int(a.A) - int(b.A)} {
+		if d < -tolerance || d > tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+func TestOrientNRGBA(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i)
+	}
+	got := orient(img, orientRotate90).(*image.NRGBA)
+	if got.Rect != image.Rect(0, 0, 2, 3) {
+		t.Fatalf("bounds %v, want 2x3", got.Rect)
+	}
+	// Rotating clockwise puts the bottom left pixel at the top left.
+	for _, p := range []struct{ x, y, sx, sy int }{{0, 0, 0, 1}, {1, 0, 0, 0}, {0, 2, 2, 1}, {1, 2, 2, 0}} {
+		if got, want := got.NRGBAAt(p.x, p.y), img.NRGBAAt(p.sx, p.sy); got != want {
+			t.Errorf("pixel (%d, %d) is %v, want %v", p.x, p.y, got, want)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package gif
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+	"time"
+
+	"github.com/renja-g/convert/internal/converter"
+	"github.com/spf13/pflag"
+)
+
+// gifDecoder and gifEncoder use the standard library's image/gif package.
+// Frame delays are stored in 1/100 s, and GIF's loop count is the number of
+// repetitions after the first play, which both differ from converter.Animation.
+
+type gifDecoder struct{}
+
+func (d *gifDecoder) Format() string { return ".gif" }
+
+func (d *gifDecoder) Decode(r io.Reader, options converter.Options) (image.Image, error) {
+	anim, err := d.DecodeAnimation(r, options)
+	if err != nil {
+		return nil, err
+	}
+	return anim.Still(options)
+}
+
+func (d *gifDecoder) DecodeAnimation(r io.Reader, options converter.Options) (*converter.Animation, error) {
+	// image/gif decodes all frames at once, so their areas are added up
+	// from the image descriptors first.
+	if limits := options.Limits(); limits.MaxPixels > 0 {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		var area int64
+		err = scan(bytes.NewReader(data), func(frame image.Rectangle) {
+			area += int64(frame.Dx()) * int64(frame.Dy())
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := limits.CheckPixels(area); err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(data)
+	}
+
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	anim := &converter.Animation{
+		Width:  g.Config.Width,
+		Height: g.Config.Height,
+	}
+	switch {
+	case g.LoopCount == 0:
+		anim.LoopCount = 0
+	case g.LoopCount < 0:
+		anim.LoopCount = 1
+	default:
+		anim.LoopCount = g.LoopCount + 1
+	}
+
+	for i, img := range g.Image {
+		frame := converter.Frame{
+			Image: img,
+			Delay: time.Duration(g.Delay[i]) * 10 * time.Millisecond,
+			Blend: true,
+		}
+		switch g.Disposal[i] {
+		case gif.DisposalBackground:
+			frame.Disposal = converter.DisposeBackground
+		case gif.DisposalPrevious:
+			frame.Disposal = converter.DisposePrevious
+		}
+		anim.Frames = append(anim.Frames, frame)
+	}
+	return anim, nil
+}
+
+func (d *gifDecoder) DecodeConfig(r io.Reader) (image.Config, error) {
+	return gif.DecodeConfig(r)
+}
+
+// CountFrames counts the image descriptors of a GIF file.
+func (d *gifDecoder) CountFrames(r io.Reader) (int, error) {
+	frames := 0
+	err := scan(r, func(image.Rectangle) { frames++ })
+	return frames, err
+}
+
+// scan calls fn with the bounds of each image descriptor of a GIF file,
+// skipping over the extensions and the compressed image data.
+func scan(r io.Reader, fn func(frame image.Rectangle)) error {
+	br := bufio.NewReader(r)
+	// The header and logical screen descriptor, maybe followed by the
+	// global colour table.
+	header := make([]byte, 13)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return err
+	}
+	if string(header[:3]) != "GIF" {
+		return errors.New("gif: not a GIF file")
+	}
+	if err := skipColorTable(br, header[10]); err != nil {
+		return err
+	}
+
+	for {
+		introducer, err := br.ReadByte()
+		if err != nil {
+			return err
+		}
+		switch introducer {
+		case 0x21: // extension: a label and data sub-blocks
+			if _, err := br.ReadByte(); err != nil {
+				return err
+			}
+		case 0x2c: // image descriptor
+			descriptor := make([]byte, 9)
+			if _, err := io.ReadFull(br, descriptor); err != nil {
+				return err
+			}
+			if err := skipColorTable(br, descriptor[8]); err != nil {
+				return err
+			}
+			// The LZW minimum code size precedes the data sub-blocks.
+			if _, err := br.ReadByte(); err != nil {
+				return err
+			}
+			le := binary.LittleEndian
+			x, y := int(le.Uint16(descriptor[0:])), int(le.Uint16(descriptor[2:]))
+			fn(image.Rect(x, y, x+int(le.Uint16(descriptor[4:])), y+int(le.Uint16(descriptor[6:]))))
+		case 0x3b: // trailer
+			return nil
+		default:
+			return fmt.Errorf("gif: unknown block type 0x%02x", introducer)
+		}
+		if err := skipSubBlocks(br); err != nil {
+			return err
+		}
+	}
+}
+
+// skipColorTable skips the colour table announced by the flags of a logical
+// screen or image descriptor.
+func skipColorTable(br *bufio.Reader, flags byte) error {
+	if flags&0x80 == 0 {
+		return nil
+	}
+	_, err := br.Discard(3 << (flags&0x07 + 1))
+	return err
+}
+
+// skipSubBlocks skips data sub-blocks up to the terminating empty block.
+func skipSubBlocks(br *bufio.Reader) error {
+	for {
+		size, err := br.ReadByte()
+		if err != nil {
+			return err
+		}
+		if size == 0 {
+			return nil
+		}
+		if _, err := br.Discard(int(size)); err != nil {
+			return err
+		}
+	}
+}
+
+func (d *gifDecoder) GetFlags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("gif", pflag.ExitOnError)
+	flags.Int("frame", -1, "Extract a single frame (0-based) of an animation")
+	return flags
+}
+
+type gifEncoder struct{}
+
+func (e *gifEncoder) Format() string { return ".gif" }
+
+// Lossy reports that the colours are reduced to a palette.
+func (e *gifEncoder) Lossy() bool { return true }
+
+func (e *gifEncoder) Encode(w io.Writer, img image.Image, options converter.Options) error {
+	return gif.Encode(w, quantize(img), nil)
+}
+
+func (e *gifEncoder) EncodeAnimation(w io.Writer, a *converter.Animation, options converter.Options) error {
+	g := &gif.GIF{
+		Config: image.Config{Width: a.Width, Height: a.Height},
+	}
+	switch a.LoopCount {
+	case 0:
+		g.LoopCount = 0
+	case 1:
+		g.LoopCount = -1
+	default:
+		g.LoopCount = a.LoopCount - 1
+	}
+
+	// GIF frames are always drawn over the canvas. Frames that replace
+	// their area with transparent pixels cannot be expressed, so in that
+	// case every frame is written as a full canvas that is cleared again
+	// before the next one.
+	frames := a.Frames
+	for _, f := range a.Frames {
+		if !f.Blend && !converter.IsOpaque(f.Image) {
+			rendered := a.Render()
+			frames = make([]converter.Frame, len(rendered))
+			for i, img := range rendered {
+				frames[i] = converter.Frame{
+					Image:    img,
+					Delay:    a.Frames[i].Delay,
+					Disposal: converter.DisposeBackground,
+				}
+			}
+			break
+		}
+	}
+
+	for _, f := range frames {
+		g.Image = append(g.Image, quantize(f.Image))
+		g.Delay = append(g.Delay, int(f.Delay/(10*time.Millisecond)))
+		switch f.Disposal {
+		case converter.DisposeBackground:
+			g.Disposal = append(g.Disposal, gif.DisposalBackground)
+		case converter.DisposePrevious:
+			g.Disposal = append(g.Disposal, gif.DisposalPrevious)
+		default:
+			g.Disposal = append(g.Disposal, gif.DisposalNone)
+		}
+	}
+	return gif.EncodeAll(w, g)
+}
+
+func (e *gifEncoder) GetFlags() *pflag.FlagSet {
+	return pflag.NewFlagSet("gif", pflag.ExitOnError)
+}
+
+// quantize converts img to the Plan 9 palette with Floyd-Steinberg
+// dithering. GIF transparency is all or nothing, so pixels that are less
+// than half opaque become transparent and the rest fully opaque.
+func quantize(img image.Image) *image.Paletted {
+	if p, ok := img.(*image.Paletted); ok {
+		return p
+	}
+
+	b := img.Bounds()
+	src := image.NewNRGBA(b)
+	draw.Draw(src, b, img, b.Min, draw.Src)
+
+	transparent := false
+	for i := 3; i < len(src.Pix); i += 4 {
+		if src.Pix[i] < 0x80 {
+			src.Pix[i-3], src.Pix[i-2], src.Pix[i-1], src.Pix[i] = 0, 0, 0, 0
+			transparent = true
+		} else {
+			src.Pix[i] = 0xff
+		}
+	}
+
+	pal := color.Palette(palette.Plan9)
+	if transparent {
+		pal = transparentPalette
+	}
+	dst := image.NewPaletted(b, pal)
+	draw.FloydSteinberg.Draw(dst, b, src, b.Min)
+	return dst
+}
+
+// transparentPalette is the Plan 9 palette with one of its two closest
+// colours replaced by transparency, which loses the least. The last entry,
+// which would be the obvious one to replace, is white.
+var transparentPalette = func() color.Palette {
+	pal := append(color.Palette(nil), palette.Plan9...)
+	best, drop := -1, 0
+	for i := range pal {
+		for j := i + 1; j < len(pal); j++ {
+			r1, g1, b1, _ := pal[i].RGBA()
+			r2, g2, b2, _ := pal[j].RGBA()
+			dr, dg, db := int(r1>>8)-int(r2>>8), int(g1>>8)-int(g2>>8), int(b1>>8)-int(b2>>8)
+			if d := dr*dr + dg*dg + db*db; best < 0 || d < best {
+				best, drop = d, i
+			}
+		}
+	}
+	pal[drop] = color.Transparent
+	return pal
+}()
+
+func init() {
+	converter.RegisterDecoder(&gifDecoder{})
+	converter.RegisterEncoder(&gifEncoder{})
+}
@@ -0,0 +1,119 @@
+package gif
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+
+	"github.com/renja-g/convert/internal/converter"
+)
+
+func TestEncodeKeepsWhiteNextToTransparency(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{255, 255, 255, 255})
+		}
+	}
+	img.SetNRGBA(0, 0, color.NRGBA{})
+
+	var buf bytes.Buffer
+	if err := (&gifEncoder{}).Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	out, err := gif.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, _, a := out.At(0, 0).RGBA(); a != 0 {
+		t.Errorf("transparent pixel has alpha %d, want 0", a)
+	}
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			if x == 0 && y == 0 {
+				continue
+			}
+			if got := color.NRGBAModel.Convert(out.At(x, y)); got != (color.NRGBA{255, 255, 255, 255}) {
+				t.Fatalf("pixel (%d, %d) is %v, want opaque white", x, y, got)
+			}
+		}
+	}
+}
+
+func TestTransparentPalette(t *testing.T) {
+	if len(transparentPalette) != 256 {
+		t.Fatalf("palette has %d colours, want 256", len(transparentPalette))
+	}
+	var transparent int
+	white, black := false, false
+	for _, c := range transparentPalette {
+		switch color.NRGBAModel.Convert(c) {
+		case color.NRGBA{}:
+			transparent++
+		case color.NRGBA{255, 255, 255, 255}:
+			white = true
+		case color.NRGBA{0, 0, 0, 255}:
+			black = true
+		}
+	}
+	if transparent != 1 || !white || !black {
+		t.Errorf("palette has %d transparent entries, white %t and black %t; want 1, true and true", transparent, white, black)
+	}
+}
+
+// TestDecodeAnimationFrameArea checks that the frames of an animation are
+// held to the pixel limit together, before they are decoded.
+func TestDecodeAnimationFrameArea(t *testing.T) {
+	g := &gif.GIF{}
+	for i := 0; i < 5; i++ {
+		g.Image = append(g.Image, image.NewPaletted(image.Rect(0, 0, 10, 10), color.Palette{color.Black, color.White}))
+		g.Delay = append(g.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		maxPixels int64
+		ok        bool
+	}{
+		{0, true},
+		{500, true},
+		{499, false},
+		{100, false},
+	} {
+		options := converter.Options{}.WithLimits(converter.Limits{MaxPixels: tt.maxPixels})
+		anim, err := (&gifDecoder{}).DecodeAnimation(bytes.NewReader(buf.Bytes()), options)
+		var limitErr *converter.LimitError
+		switch {
+		case tt.ok && err != nil:
+			t.Errorf("limit %d: %v", tt.maxPixels, err)
+		case tt.ok && len(anim.Frames) != 5:
+			t.Errorf("limit %d: decoded %d frames, want 5", tt.maxPixels, len(anim.Frames))
+		case !tt.ok && !errors.As(err, &limitErr):
+			t.Errorf("limit %d: got %v, want a LimitError", tt.maxPixels, err)
+		case !tt.ok && limitErr.Value != 500:
+			t.Errorf("limit %d: LimitError value %d, want 500", tt.maxPixels, limitErr.Value)
+		}
+	}
+}
+
+func TestCountFrames(t *testing.T) {
+	g := &gif.GIF{}
+	for i := 0; i < 3; i++ {
+		g.Image = append(g.Image, image.NewPaletted(image.Rect(0, 0, 4, 4), color.Palette{color.Black, color.White}))
+		g.Delay = append(g.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := (&gifDecoder{}).CountFrames(&buf); err != nil || n != 3 {
+		t.Errorf("CountFrames = %d, %v, want 3", n, err)
+	}
+}
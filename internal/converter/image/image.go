@@ -4,6 +4,7 @@ package image
 
 import (
 	// Import all converter subpackages for side-effect of registration
+	_ "github.com/renja-g/convert/internal/converter/image/gif"
 	_ "github.com/renja-g/convert/internal/converter/image/jpeg"
 	_ "github.com/renja-g/convert/internal/converter/image/png"
 	_ "github.com/renja-g/convert/internal/converter/image/webp"
@@ -0,0 +1,49 @@
+package jpeg
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strconv"
+	"strings"
+)
+
+var namedColors = map[string]color.RGBA{
+	"white": {0xff, 0xff, 0xff, 0xff},
+	"black": {0x00, 0x00, 0x00, 0xff},
+	"gray":  {0x80, 0x80, 0x80, 0xff},
+	"red":   {0xff, 0x00, 0x00, 0xff},
+	"green": {0x00, 0x80, 0x00, 0xff},
+	"blue":  {0x00, 0x00, 0xff, 0xff},
+}
+
+// parseColor parses a colour name or a hex colour such as "#fff" or "#ffffff".
+func parseColor(s string) (color.RGBA, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if c, ok := namedColors[s]; ok {
+		return c, nil
+	}
+
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid colour %q", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid colour %q", s)
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xff}, nil
+}
+
+// flatten composites img onto a solid background colour.
+func flatten(img image.Image, bg color.Color) *image.RGBA {
+	b := img.Bounds()
+	dst := image.NewRGBA(b)
+	draw.Draw(dst, b, image.NewUniform(bg), image.Point{}, draw.Src)
+	draw.Draw(dst, b, img, b.Min, draw.Over)
+	return dst
+}
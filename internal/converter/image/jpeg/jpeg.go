@@ -0,0 +1,87 @@
+package jpeg
+
+import (
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+
+	"github.com/renja-g/convert/internal/converter"
+	"github.com/spf13/pflag"
+)
+
+type jpegDecoder struct{}
+
+func (d *jpegDecoder) Format() string { return ".jpeg" }
+
+func (d *jpegDecoder) Decode(r io.Reader, options converter.Options) (image.Image, error) {
+	return jpeg.Decode(r)
+}
+
+func (d *jpegDecoder) DecodeConfig(r io.Reader) (image.Config, error) {
+	return jpeg.DecodeConfig(r)
+}
+
+func (d *jpegDecoder) GetFlags() *pflag.FlagSet {
+	return pflag.NewFlagSet("jpeg", pflag.ExitOnError)
+}
+
+// Alias for .jpg
+type jpegDecoderAliasJpg struct {
+	jpegDecoder
+}
+
+func (d *jpegDecoderAliasJpg) Format() string { return ".jpg" }
+
+type jpegEncoder struct{}
+
+func (e *jpegEncoder) Format() string { return ".jpeg" }
+
+// Lossy reports that JPEG compression loses detail.
+func (e *jpegEncoder) Lossy() bool { return true }
+
+func (e *jpegEncoder) Encode(w io.Writer, img image.Image, options converter.Options) error {
+	quality := options.Int("quality", jpeg.DefaultQuality)
+	if quality < 1 || quality > 100 {
+		return fmt.Errorf("jpeg: quality must be between 1 and 100, got %d", quality)
+	}
+
+	name := options.String("subsampling", "420")
+	sub, ok := subsamplings[name]
+	if !ok {
+		return fmt.Errorf("jpeg: unknown chroma subsampling %q (want 444, 422 or 420)", name)
+	}
+
+	bg, err := parseColor(options.String("background", "white"))
+	if err != nil {
+		return fmt.Errorf("jpeg: %w", err)
+	}
+	// JPEG has no alpha channel, so transparent areas are filled with the
+	// background colour instead of whatever the colour channels hold.
+	if !converter.IsOpaque(img) {
+		options.Warn("JPEG does not support transparency; flattening onto %s", options.String("background", "white"))
+		img = flatten(img, bg)
+	}
+
+	return encode(w, img, encodeOptions{
+		quality:     quality,
+		progressive: options.Bool("progressive", false),
+		subsampling: sub,
+	})
+}
+
+func (e *jpegEncoder) GetFlags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("jpeg", pflag.ExitOnError)
+	flags.Int("quality", jpeg.DefaultQuality, "JPEG quality (1-100)")
+	flags.Bool("progressive", false, "Write a progressive JPEG")
+	flags.String("subsampling", "420", "Chroma subsampling: 444, 422 or 420")
+	flags.String("background", "white", "Background colour for transparent areas (name or hex, e.g. #ffffff)")
+	return flags
+}
+
+func init() {
+	converter.RegisterDecoder(&jpegDecoder{})
+	// Also handle .jpg
+	converter.RegisterDecoder(&jpegDecoderAliasJpg{})
+	converter.RegisterEncoder(&jpegEncoder{})
+}
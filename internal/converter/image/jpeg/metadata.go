@@ -0,0 +1,176 @@
+package jpeg
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/renja-g/convert/internal/converter"
+	"github.com/renja-g/convert/internal/exif"
+)
+
+// JPEG markers used when reading metadata.
+const (
+	markerSOI  = 0xd8
+	markerEOI  = 0xd9
+	markerSOS  = 0xda
+	markerRST0 = 0xd0
+	markerRST7 = 0xd7
+	markerTEM  = 0x01
+	markerAPP0 = 0xe0
+	markerAPP1 = 0xe1
+	markerAPP2 = 0xe2
+)
+
+// Identifiers that start the payload of metadata segments.
+const (
+	xmpHeader = "http://ns.adobe.com/xap/1.0/\x00"
+	iccHeader = "ICC_PROFILE\x00"
+)
+
+// maxSegment is the largest payload of a marker segment.
+const maxSegment = 1<<16 - 1 - 2
+
+// DecodeMetadata reads the metadata segments that precede the image data.
+func (d *jpegDecoder) DecodeMetadata(r io.Reader) (*converter.Metadata, error) {
+	br := bufio.NewReader(r)
+	var soi [2]byte
+	if _, err := io.ReadFull(br, soi[:]); err != nil {
+		return nil, err
+	}
+	if soi[0] != 0xff || soi[1] != markerSOI {
+		return nil, errors.New("jpeg: missing SOI marker")
+	}
+
+	md := &converter.Metadata{}
+	// ICC profiles are split into numbered chunks.
+	var icc [][]byte
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b != 0xff {
+			return nil, errors.New("jpeg: invalid marker")
+		}
+		// Any number of 0xff fill bytes may precede a marker.
+		marker := byte(0xff)
+		for marker == 0xff {
+			if marker, err = br.ReadByte(); err != nil {
+				return nil, err
+			}
+		}
+		switch {
+		case marker == markerSOS || marker == markerEOI:
+			md.ICC = joinICC(icc)
+			return md, nil
+		case marker >= markerRST0 && marker <= markerRST7 || marker == markerTEM:
+			// These markers have no payload.
+			continue
+		}
+
+		var length [2]byte
+		if _, err := io.ReadFull(br, length[:]); err != nil {
+			return nil, err
+		}
+		n := int(binary.BigEndian.Uint16(length[:])) - 2
+		if n < 0 {
+			return nil, errors.New("jpeg: invalid segment length")
+		}
+		if marker != markerAPP1 && marker != markerAPP2 {
+			if _, err := br.Discard(n); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(br, payload); err != nil {
+			return nil, err
+		}
+		switch {
+		case marker == markerAPP1 && md.EXIF == nil && bytes.HasPrefix(payload, []byte(exif.Header)):
+			md.EXIF = payload[len(exif.Header):]
+		case marker == markerAPP1 && md.XMP == nil && bytes.HasPrefix(payload, []byte(xmpHeader)):
+			md.XMP = payload[len(xmpHeader):]
+		case marker == markerAPP2 && bytes.HasPrefix(payload, []byte(iccHeader)) && len(payload) >= len(iccHeader)+2:
+			// The chunk's sequence number, from 1, and the number of chunks.
+			seq, count := int(payload[len(iccHeader)]), int(payload[len(iccHeader)+1])
+			if icc == nil {
+				icc = make([][]byte, count)
+			}
+			if seq >= 1 && seq <= len(icc) {
+				icc[seq-1] = payload[len(iccHeader)+2:]
+			}
+		}
+	}
+}
+
+// joinICC assembles the chunks of an ICC profile. A profile with missing
+// chunks is ignored.
+func joinICC(chunks [][]byte) []byte {
+	var icc []byte
+	for _, c := range chunks {
+		if c == nil {
+			return nil
+		}
+		icc = append(icc, c...)
+	}
+	return icc
+}
+
+// EmbedMetadata inserts the metadata segments after the start of the image
+// and the JFIF header, if there is one. EXIF and XMP blocks that don't fit
+// in a segment are dropped.
+func (e *jpegEncoder) EmbedMetadata(data []byte, md *converter.Metadata, options converter.Options) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0xff || data[1] != markerSOI {
+		return nil, errors.New("jpeg: missing SOI marker")
+	}
+	pos := 2
+	if len(data) >= pos+4 && data[pos] == 0xff && data[pos+1] == markerAPP0 {
+		pos += 2 + int(binary.BigEndian.Uint16(data[pos+2:]))
+	}
+
+	var segments []byte
+	if md.EXIF != nil {
+		if len(exif.Header)+len(md.EXIF) > maxSegment {
+			options.Warn("EXIF metadata of %d bytes is too large for JPEG; dropping it", len(md.EXIF))
+		} else {
+			segments = appendSegment(segments, markerAPP1, []byte(exif.Header), md.EXIF)
+		}
+	}
+	if md.XMP != nil {
+		if len(xmpHeader)+len(md.XMP) > maxSegment {
+			options.Warn("XMP metadata of %d bytes is too large for JPEG; dropping it", len(md.XMP))
+		} else {
+			segments = appendSegment(segments, markerAPP1, []byte(xmpHeader), md.XMP)
+		}
+	}
+	if md.ICC != nil {
+		chunkSize := maxSegment - len(iccHeader) - 2
+		count := (len(md.ICC) + chunkSize - 1) / chunkSize
+		if count > 255 {
+			return nil, errors.New("jpeg: ICC profile is too large")
+		}
+		for i := 0; i < count; i++ {
+			chunk := md.ICC[i*chunkSize : min((i+1)*chunkSize, len(md.ICC))]
+			header := append([]byte(iccHeader), byte(i+1), byte(count))
+			segments = appendSegment(segments, markerAPP2, header, chunk)
+		}
+	}
+
+	out := make([]byte, 0, len(data)+len(segments))
+	out = append(out, data[:pos]...)
+	out = append(out, segments...)
+	return append(out, data[pos:]...), nil
+}
+
+// appendSegment appends a marker segment whose payload is header followed
+// by payload.
+func appendSegment(dst []byte, marker byte, header, payload []byte) []byte {
+	dst = append(dst, 0xff, marker)
+	dst = binary.BigEndian.AppendUint16(dst, uint16(2+len(header)+len(payload)))
+	dst = append(dst, header...)
+	return append(dst, payload...)
+}
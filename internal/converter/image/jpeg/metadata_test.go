@@ -0,0 +1,71 @@
+package jpeg
+
+import (
+	"bytes"
+	"image/jpeg"
+	"strings"
+	"testing"
+
+	"github.com/renja-g/convert/internal/converter"
+)
+
+func TestMetadataRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(16, 8), nil); err != nil {
+		t.Fatal(err)
+	}
+	md := &converter.Metadata{
+		EXIF: []byte("II*\x00\x08\x00\x00\x00\x00\x00\x00\x00\x00\x00"),
+		XMP:  []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/"/>`),
+		// Too large for one segment, so it is split into three.
+		ICC: bytes.Repeat([]byte("icc profile "), 12000),
+	}
+	data, err := (&jpegEncoder{}).EmbedMetadata(buf.Bytes(), md, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(data, []byte(iccHeader)); n != 3 {
+		t.Errorf("the ICC profile was written in %d segments, want 3", n)
+	}
+
+	got, err := (&jpegDecoder{}).DecodeMetadata(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.EXIF, md.EXIF) {
+		t.Errorf("EXIF = %q, want %q", got.EXIF, md.EXIF)
+	}
+	if !bytes.Equal(got.XMP, md.XMP) {
+		t.Errorf("XMP = %q, want %q", got.XMP, md.XMP)
+	}
+	if !bytes.Equal(got.ICC, md.ICC) {
+		t.Errorf("ICC profile of %d bytes, want %d", len(got.ICC), len(md.ICC))
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(data)); err != nil {
+		t.Errorf("decoding the file with metadata: %v", err)
+	}
+}
+
+func TestEmbedMetadataDropsLargeEXIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(8, 8), nil); err != nil {
+		t.Fatal(err)
+	}
+	var warnings []string
+	options := converter.Options{}.WithWarningFunc(func(s string) { warnings = append(warnings, s) })
+	md := &converter.Metadata{EXIF: make([]byte, maxSegment), XMP: []byte("<x/>")}
+	data, err := (&jpegEncoder{}).EmbedMetadata(buf.Bytes(), md, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "EXIF") {
+		t.Errorf("warnings = %q, want one about the EXIF block", warnings)
+	}
+	got, err := (&jpegDecoder{}).DecodeMetadata(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.EXIF != nil || string(got.XMP) != "<x/>" {
+		t.Errorf("DecodeMetadata = EXIF of %d bytes and XMP %q, want only the XMP", len(got.EXIF), got.XMP)
+	}
+}
@@ -0,0 +1,565 @@
+package jpeg
+
+import (
+	"bufio"
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"math"
+)
+
+// The standard library's image/jpeg encoder only writes baseline 4:2:0
+// images, so this file implements a small encoder that additionally supports
+// 4:4:4 and 4:2:2 chroma subsampling and progressive output. It uses the
+// example quantization and Huffman tables from Annex K of the JPEG spec.
+// Baseline 4:2:0 images are still written by the standard library.
+
+const blockSize = 64
+
+// encodeOptions are the parameters of encode.
+type encodeOptions struct {
+	quality     int
+	progressive bool
+	subsampling subsampling
+}
+
+// subsampling holds the luma sampling factors of a chroma subsampling mode;
+// the chroma components always use a factor of 1.
+type subsampling struct {
+	h, v int
+}
+
+var subsamplings = map[string]subsampling{
+	"444": {1, 1},
+	"422": {2, 1},
+	"420": {2, 2},
+}
+
+// unzig maps from the zig-zag ordering to the natural ordering.
+var unzig = [blockSize]int{
+	0, 1, 8, 16, 9, 2, 3, 10,
+	17, 24, 32, 25, 18, 11, 4, 5,
+	12, 19, 26, 33, 40, 48, 41, 34,
+	27, 20, 13, 6, 7, 14, 21, 28,
+	35, 42, 49, 56, 57, 50, 43, 36,
+	29, 22, 15, 23, 30, 37, 44, 51,
+	58, 59, 52, 45, 38, 31, 39, 46,
+	53, 60, 61, 54, 47, 55, 62, 63,
+}
+
+// unscaledQuant are the luminance and chrominance quantization tables in
+// zig-zag order, before scaling by quality.
+var unscaledQuant = [2][blockSize]byte{
+	{
+		16, 11, 12, 14, 12, 10, 16, 14,
+		13, 14, 18, 17, 16, 19, 24, 40,
+		26, 24, 22, 22, 24, 49, 35, 37,
+		29, 40, 58, 51, 61, 60, 57, 51,
+		56, 55, 64, 72, 92, 78, 64, 68,
+		87, 69, 55, 56, 80, 109, 81, 87,
+		95, 98, 103, 104, 103, 62, 77, 113,
+		121, 112, 100, 120, 92, 101, 103, 99,
+	},
+	{
+		17, 18, 18, 24, 21, 24, 47, 26,
+		26, 47, 99, 66, 56, 66, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+	},
+}
+
+// huffmanSpec specifies a Huffman table as stored in a DHT segment.
+type huffmanSpec struct {
+	// count[i] is the number of codes of length i+1 bits.
+	count [16]byte
+	// value[i] is the symbol of the i'th codeword.
+	value []byte
+}
+
+// huffmanSpecs are the luminance DC, luminance AC, chrominance DC and
+// chrominance AC tables, in that order.
+var huffmanSpecs = [4]huffmanSpec{
+	{
+		[16]byte{0, 1, 5, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0, 0, 0},
+		[]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+	},
+	{
+		[16]byte{0, 2, 1, 3, 3, 2, 4, 3, 5, 5, 4, 4, 0, 0, 1, 125},
+		[]byte{
+			0x01, 0x02, 0x03, 0x00, 0x04, 0x11, 0x05, 0x12,
+			0x21, 0x31, 0x41, 0x06, 0x13, 0x51, 0x61, 0x07,
+			0x22, 0x71, 0x14, 0x32, 0x81, 0x91, 0xa1, 0x08,
+			0x23, 0x42, 0xb1, 0xc1, 0x15, 0x52, 0xd1, 0xf0,
+			0x24, 0x33, 0x62, 0x72, 0x82, 0x09, 0x0a, 0x16,
+			0x17, 0x18, 0x19, 0x1a, 0x25, 0x26, 0x27, 0x28,
+			0x29, 0x2a, 0x34, 0x35, 0x36, 0x37, 0x38, 0x39,
+			0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48, 0x49,
+			0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59,
+			0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69,
+			0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78, 0x79,
+			0x7a, 0x83, 0x84, 0x85, 0x86, 0x87, 0x88, 0x89,
+			0x8a, 0x92, 0x93, 0x94, 0x95, 0x96, 0x97, 0x98,
+			0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7,
+			0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4, 0xb5, 0xb6,
+			0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3, 0xc4, 0xc5,
+			0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2, 0xd3, 0xd4,
+			0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda, 0xe1, 0xe2,
+			0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9, 0xea,
+			0xf1, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+			0xf9, 0xfa,
+		},
+	},
+	{
+		[16]byte{0, 3, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0},
+		[]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+	},
+	{
+		[16]byte{0, 2, 1, 2, 4, 4, 3, 4, 7, 5, 4, 4, 0, 1, 2, 119},
+		[]byte{
+			0x00, 0x01, 0x02, 0x03, 0x11, 0x04, 0x05, 0x21,
+			0x31, 0x06, 0x12, 0x41, 0x51, 0x07, 0x61, 0x71,
+			0x13, 0x22, 0x32, 0x81, 0x08, 0x14, 0x42, 0x91,
+			0xa1, 0xb1, 0xc1, 0x09, 0x23, 0x33, 0x52, 0xf0,
+			0x15, 0x62, 0x72, 0xd1, 0x0a, 0x16, 0x24, 0x34,
+			0xe1, 0x25, 0xf1, 0x17, 0x18, 0x19, 0x1a, 0x26,
+			0x27, 0x28, 0x29, 0x2a, 0x35, 0x36, 0x37, 0x38,
+			0x39, 0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48,
+			0x49, 0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58,
+			0x59, 0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68,
+			0x69, 0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78,
+			0x79, 0x7a, 0x82, 0x83, 0x84, 0x85, 0x86, 0x87,
+			0x88, 0x89, 0x8a, 0x92, 0x93, 0x94, 0x95, 0x96,
+			0x97, 0x98, 0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5,
+			0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4,
+			0xb5, 0xb6, 0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3,
+			0xc4, 0xc5, 0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2,
+			0xd3, 0xd4, 0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda,
+			0xe2, 0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9,
+			0xea, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+			0xf9, 0xfa,
+		},
+	},
+}
+
+// huffmanLUTs map each symbol of huffmanSpecs to its codeword. The 8 most
+// significant bits hold the codeword length, the rest the codeword itself.
+var huffmanLUTs [4][256]uint32
+
+// dctTable[u][x] is the DCT basis function u sampled at x, including the
+// normalisation factors.
+var dctTable [8][8]float64
+
+func init() {
+	for i, s := range huffmanSpecs {
+		code, k := uint32(0), 0
+		for n, count := range s.count {
+			for j := byte(0); j < count; j++ {
+				huffmanLUTs[i][s.value[k]] = uint32(n+1)<<24 | code
+				code++
+				k++
+			}
+			code <<= 1
+		}
+	}
+
+	for u := 0; u < 8; u++ {
+		c := 0.5
+		if u == 0 {
+			c = 0.5 / math.Sqrt2
+		}
+		for x := 0; x < 8; x++ {
+			dctTable[u][x] = c * math.Cos(float64((2*x+1)*u)*math.Pi/16)
+		}
+	}
+}
+
+// component is one colour channel of the image being encoded.
+type component struct {
+	// h and v are the horizontal and vertical sampling factors.
+	h, v int
+	// table selects the quantization and Huffman tables: 0 for luminance,
+	// 1 for chrominance.
+	table int
+	// width and height are the number of blocks per row and column of the
+	// MCU-aligned grid, used by interleaved scans.
+	width, height int
+	// scanWidth and scanHeight are the number of blocks that actually cover
+	// the component, used by single-component scans.
+	scanWidth, scanHeight int
+	// blocks holds the quantized DCT coefficients in zig-zag order.
+	blocks [][blockSize]int32
+}
+
+// jpegWriter accumulates bits and writes the encoded stream.
+type jpegWriter struct {
+	w     *bufio.Writer
+	err   error
+	bits  uint32
+	nBits uint32
+	quant [2][blockSize]byte
+}
+
+// encode writes m to w as a JPEG image.
+func encode(w io.Writer, m image.Image, o encodeOptions) error {
+	b := m.Bounds()
+	if b.Dx() >= 1<<16 || b.Dy() >= 1<<16 {
+		return errors.New("jpeg: image is too large to encode")
+	}
+	if b.Empty() {
+		return errors.New("jpeg: image is empty")
+	}
+	if !o.progressive && o.subsampling == subsamplings["420"] {
+		return jpeg.Encode(w, m, &jpeg.Options{Quality: o.quality})
+	}
+
+	e := &jpegWriter{w: bufio.NewWriter(w)}
+	e.initQuant(o.quality)
+
+	comps := e.components(m, o.subsampling)
+
+	e.write([]byte{0xff, 0xd8})
+	e.writeDQT(len(comps))
+	e.writeSOF(b.Size(), comps, o.progressive)
+	e.writeDHT(len(comps))
+
+	all := make([]int, len(comps))
+	for i := range comps {
+		all[i] = i
+	}
+	if o.progressive {
+		// A DC scan for all components followed by one AC scan per component.
+		e.writeScan(comps, all, 0, 0)
+		for i := range comps {
+			e.writeScan(comps, []int{i}, 1, 63)
+		}
+	} else {
+		e.writeScan(comps, all, 0, 63)
+	}
+
+	e.write([]byte{0xff, 0xd9})
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+// initQuant scales the quantization tables to the given quality.
+func (e *jpegWriter) initQuant(quality int) {
+	var scale int
+	if quality < 50 {
+		scale = 5000 / quality
+	} else {
+		scale = 200 - quality*2
+	}
+	for i := range e.quant {
+		for j := range e.quant[i] {
+			x := (int(unscaledQuant[i][j])*scale + 50) / 100
+			e.quant[i][j] = uint8(min(max(x, 1), 255))
+		}
+	}
+}
+
+// components converts m to YCbCr (or gray) planes, subsamples the chroma
+// planes and computes the quantized DCT coefficients of every block.
+func (e *jpegWriter) components(m image.Image, s subsampling) []*component {
+	b := m.Bounds()
+	width, height := b.Dx(), b.Dy()
+
+	var planes [][]int32
+	var comps []*component
+	if gray, ok := m.(*image.Gray); ok {
+		y := make([]int32, width*height)
+		for j := 0; j < height; j++ {
+			for i := 0; i < width; i++ {
+				y[j*width+i] = int32(gray.Pix[gray.PixOffset(b.Min.X+i, b.Min.Y+j)])
+			}
+		}
+		planes = [][]int32{y}
+		comps = []*component{{h: 1, v: 1, table: 0}}
+	} else {
+		rgba, ok := m.(*image.RGBA)
+		if !ok {
+			rgba = image.NewRGBA(b)
+			draw.Draw(rgba, b, m, b.Min, draw.Src)
+		}
+		y := make([]int32, width*height)
+		cb := make([]int32, width*height)
+		cr := make([]int32, width*height)
+		for j := 0; j < height; j++ {
+			for i := 0; i < width; i++ {
+				p := rgba.Pix[rgba.PixOffset(b.Min.X+i, b.Min.Y+j):]
+				r, g, bl := int32(p[0]), int32(p[1]), int32(p[2])
+				// JFIF YCbCr conversion in 16.16 fixed point.
+				y[j*width+i] = (19595*r + 38470*g + 7471*bl + 1<<15) >> 16
+				cb[j*width+i] = (-11056*r - 21712*g + 32768*bl + 257<<15) >> 16
+				cr[j*width+i] = (32768*r - 27440*g - 5328*bl + 257<<15) >> 16
+			}
+		}
+		planes = [][]int32{y, cb, cr}
+		comps = []*component{
+			{h: s.h, v: s.v, table: 0},
+			{h: 1, v: 1, table: 1},
+			{h: 1, v: 1, table: 1},
+		}
+	}
+
+	hMax, vMax := comps[0].h, comps[0].v
+	mcuX := (width + 8*hMax - 1) / (8 * hMax)
+	mcuY := (height + 8*vMax - 1) / (8 * vMax)
+
+	for i, c := range comps {
+		// Dimensions of the component after subsampling.
+		sx, sy := hMax/c.h, vMax/c.v
+		cw := (width + sx - 1) / sx
+		ch := (height + sy - 1) / sy
+
+		c.width, c.height = mcuX*c.h, mcuY*c.v
+		c.scanWidth, c.scanHeight = (cw+7)/8, (ch+7)/8
+		c.blocks = make([][blockSize]int32, c.width*c.height)
+
+		// sample returns the component value at (x, y), averaging the
+		// source pixels it covers and replicating the image edges.
+		plane := planes[i]
+		sample := func(x, y int) int32 {
+			x, y = min(x, cw-1), min(y, ch-1)
+			var sum, n int32
+			for j := y * sy; j < min((y+1)*sy, height); j++ {
+				for k := x * sx; k < min((x+1)*sx, width); k++ {
+					sum += plane[j*width+k]
+					n++
+				}
+			}
+			return (sum + n/2) / n
+		}
+
+		var pixels [blockSize]float64
+		for by := 0; by < c.height; by++ {
+			for bx := 0; bx < c.width; bx++ {
+				for j := 0; j < 8; j++ {
+					for k := 0; k < 8; k++ {
+						pixels[j*8+k] = float64(sample(bx*8+k, by*8+j) - 128)
+					}
+				}
+				e.quantize(&c.blocks[by*c.width+bx], &pixels, c.table)
+			}
+		}
+	}
+	return comps
+}
+
+// quantize computes the DCT of the level-shifted pixels (in natural order)
+// and stores the quantized coefficients in zig-zag order in dst.
+func (e *jpegWriter) quantize(dst *[blockSize]int32, pixels *[blockSize]float64, table int) {
+	var rows, coef [blockSize]float64
+	for y := 0; y < 8; y++ {
+		for u := 0; u < 8; u++ {
+			var sum float64
+			for x := 0; x < 8; x++ {
+				sum += dctTable[u][x] * pixels[y*8+x]
+			}
+			rows[y*8+u] = sum
+		}
+	}
+	for v := 0; v < 8; v++ {
+		for u := 0; u < 8; u++ {
+			var sum float64
+			for y := 0; y < 8; y++ {
+				sum += dctTable[v][y] * rows[y*8+u]
+			}
+			coef[v*8+u] = sum
+		}
+	}
+	for zig := 0; zig < blockSize; zig++ {
+		q := math.Round(coef[unzig[zig]] / float64(e.quant[table][zig]))
+		if zig > 0 {
+			// AC coefficients must fit in the 10 bits of the Huffman tables.
+			q = min(max(q, -1023), 1023)
+		}
+		dst[zig] = int32(q)
+	}
+}
+
+func (e *jpegWriter) write(p []byte) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = e.w.Write(p)
+}
+
+func (e *jpegWriter) writeByte(b byte) {
+	if e.err != nil {
+		return
+	}
+	e.err = e.w.WriteByte(b)
+}
+
+// writeMarker writes a marker segment header for a payload of n bytes.
+func (e *jpegWriter) writeMarker(marker byte, n int) {
+	e.write([]byte{0xff, marker, byte((n + 2) >> 8), byte(n + 2)})
+}
+
+func (e *jpegWriter) writeDQT(nComponent int) {
+	nTables := min(nComponent, 2)
+	e.writeMarker(0xdb, nTables*(1+blockSize))
+	for i := 0; i < nTables; i++ {
+		e.writeByte(byte(i))
+		e.write(e.quant[i][:])
+	}
+}
+
+func (e *jpegWriter) writeSOF(size image.Point, comps []*component, progressive bool) {
+	marker := byte(0xc0)
+	if progressive {
+		marker = 0xc2
+	}
+	e.writeMarker(marker, 6+3*len(comps))
+	e.write([]byte{8, byte(size.Y >> 8), byte(size.Y), byte(size.X >> 8), byte(size.X), byte(len(comps))})
+	for i, c := range comps {
+		e.write([]byte{byte(i + 1), byte(c.h<<4 | c.v), byte(c.table)})
+	}
+}
+
+func (e *jpegWriter) writeDHT(nComponent int) {
+	specs := huffmanSpecs[:]
+	if nComponent == 1 {
+		specs = specs[:2]
+	}
+	n := 0
+	for _, s := range specs {
+		n += 1 + 16 + len(s.value)
+	}
+	e.writeMarker(0xc4, n)
+	for i, s := range specs {
+		// Table class (0 = DC, 1 = AC) and destination.
+		e.writeByte(byte((i%2)<<4 | i/2))
+		e.write(s.count[:])
+		e.write(s.value)
+	}
+}
+
+// writeScan writes a scan of the spectral band [ss, se] for the given
+// components. Scans with more than one component are interleaved by MCU.
+func (e *jpegWriter) writeScan(comps []*component, indices []int, ss, se int) {
+	e.writeMarker(0xda, 4+2*len(indices))
+	e.writeByte(byte(len(indices)))
+	for _, i := range indices {
+		// DC and AC table selectors; progressive scans only use one of them.
+		t := byte(comps[i].table)
+		td, ta := t, t
+		if ss > 0 {
+			td = 0
+		}
+		if se == 0 {
+			ta = 0
+		}
+		e.write([]byte{byte(i + 1), td<<4 | ta})
+	}
+	e.write([]byte{byte(ss), byte(se), 0})
+
+	prevDC := make([]int32, len(comps))
+	if len(indices) == 1 {
+		c := comps[indices[0]]
+		for by := 0; by < c.scanHeight; by++ {
+			for bx := 0; bx < c.scanWidth; bx++ {
+				prevDC[indices[0]] = e.writeBlock(&c.blocks[by*c.width+bx], c.table, prevDC[indices[0]], ss, se)
+			}
+		}
+	} else {
+		mcuX, mcuY := comps[0].width/comps[0].h, comps[0].height/comps[0].v
+		for my := 0; my < mcuY; my++ {
+			for mx := 0; mx < mcuX; mx++ {
+				for _, i := range indices {
+					c := comps[i]
+					for v := 0; v < c.v; v++ {
+						for h := 0; h < c.h; h++ {
+							blk := &c.blocks[(my*c.v+v)*c.width+mx*c.h+h]
+							prevDC[i] = e.writeBlock(blk, c.table, prevDC[i], ss, se)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	// Pad the last byte with 1's.
+	e.emit(0x7f, 7)
+	e.bits, e.nBits = 0, 0
+}
+
+// writeBlock emits the coefficients of blk in the band [ss, se] and returns
+// the block's DC value for prediction of the next one.
+func (e *jpegWriter) writeBlock(blk *[blockSize]int32, table int, prevDC int32, ss, se int) int32 {
+	dc, ac := 2*table, 2*table+1
+	if ss == 0 {
+		e.emitValue(dc, 0, blk[0]-prevDC)
+		ss = 1
+	}
+	if se == 0 {
+		return blk[0]
+	}
+
+	run := int32(0)
+	for k := ss; k <= se; k++ {
+		if blk[k] == 0 {
+			run++
+			continue
+		}
+		for run > 15 {
+			e.emitHuff(ac, 0xf0)
+			run -= 16
+		}
+		e.emitValue(ac, run, blk[k])
+		run = 0
+	}
+	if run > 0 {
+		// End of block.
+		e.emitHuff(ac, 0x00)
+	}
+	return blk[0]
+}
+
+// emit writes the low nBits bits of bits, stuffing a zero after every 0xff.
+func (e *jpegWriter) emit(bits, nBits uint32) {
+	nBits += e.nBits
+	bits <<= 32 - nBits
+	bits |= e.bits
+	for nBits >= 8 {
+		b := byte(bits >> 24)
+		e.writeByte(b)
+		if b == 0xff {
+			e.writeByte(0x00)
+		}
+		bits <<= 8
+		nBits -= 8
+	}
+	e.bits, e.nBits = bits, nBits
+}
+
+func (e *jpegWriter) emitHuff(table int, symbol byte) {
+	x := huffmanLUTs[table][symbol]
+	e.emit(x&(1<<24-1), x>>24)
+}
+
+// emitValue emits a run length and non-zero coefficient (or DC difference)
+// as a Huffman coded symbol followed by the value's extra bits.
+func (e *jpegWriter) emitValue(table int, run, value int32) {
+	a, b := value, value
+	if a < 0 {
+		a, b = -value, value-1
+	}
+	var size uint32
+	for a > 0 {
+		size++
+		a >>= 1
+	}
+	e.emitHuff(table, byte(run<<4)|byte(size))
+	if size > 0 {
+		e.emit(uint32(b)&(1<<size-1), size)
+	}
+}
@@ -0,0 +1,129 @@
+package jpeg
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"testing"
+)
+
+// testImage returns a smooth w × h image, which JPEG compresses well.
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{
+				uint8(255 * x / max(w-1, 1)),
+				uint8(255 * y / max(h-1, 1)),
+				uint8(128 + 100*math.Sin(float64(x+y)/10)),
+				255,
+			})
+		}
+	}
+	return img
+}
+
+func testGray(w, h int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetGray(x, y, color.Gray{uint8(255 * (x + y) / max(w+h-2, 1))})
+		}
+	}
+	return img
+}
+
+// psnr returns the peak signal-to-noise ratio of b compared to a, over the
+// red, green and blue channels, in dB.
+func psnr(a, b image.Image) float64 {
+	var sum float64
+	n := 0
+	bounds := a.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r1, g1, b1, _ := a.At(x, y).RGBA()
+			r2, g2, b2, _ := b.At(x, y).RGBA()
+			for _, d := range []float64{
+				float64(r1>>8) - float64(r2>>8),
+				float64(g1>>8) - float64(g2>>8),
+				float64(b1>>8) - float64(b2>>8),
+			} {
+				sum += d * d
+				n++
+			}
+		}
+	}
+	if sum == 0 {
+		return math.Inf(1)
+	}
+	return 10 * math.Log10(255*255/(sum/float64(n)))
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	images := map[string]image.Image{
+		"1x1":        testImage(1, 1),
+		"17x9":       testImage(17, 9),
+		"64x48":      testImage(64, 48),
+		"gray 1x1":   testGray(1, 1),
+		"gray 17x9":  testGray(17, 9),
+		"gray 64x48": testGray(64, 48),
+	}
+	// The lowest PSNR accepted for each subsampling and quality. Chroma
+	// subsampling costs more than quantization on the steep gradients of
+	// the small images.
+	minPSNR := map[string]map[int]float64{
+		"444": {1: 15, 75: 38, 100: 48},
+		"422": {1: 15, 75: 33, 100: 35},
+		"420": {1: 15, 75: 27, 100: 27},
+	}
+
+	for name, img := range images {
+		for sub, qualities := range minPSNR {
+			for _, progressive := range []bool{false, true} {
+				for quality, want := range qualities {
+					t.Run(fmt.Sprintf("%s/%s/progressive=%t/q%d", name, sub, progressive, quality), func(t *testing.T) {
+						var buf bytes.Buffer
+						err := encode(&buf, img, encodeOptions{quality: quality, progressive: progressive, subsampling: subsamplings[sub]})
+						if err != nil {
+							t.Fatal(err)
+						}
+						out, err := jpeg.Decode(&buf)
+						if err != nil {
+							t.Fatalf("decoding: %v", err)
+						}
+						if got, want := out.Bounds().Size(), img.Bounds().Size(); got != want {
+							t.Fatalf("decoded size %v, want %v", got, want)
+						}
+						if _, isGray := img.(*image.Gray); isGray {
+							if _, ok := out.(*image.Gray); !ok {
+								t.Errorf("decoded %T, want *image.Gray", out)
+							}
+						}
+						if p := psnr(img, out); p < want {
+							t.Errorf("PSNR %.1f dB, want at least %.0f dB", p, want)
+						}
+					})
+				}
+			}
+		}
+	}
+}
+
+// TestEncodeDefaultUsesStandardLibrary checks that baseline 4:2:0 images
+// are written by image/jpeg.
+func TestEncodeDefaultUsesStandardLibrary(t *testing.T) {
+	img := testImage(33, 17)
+	var got, want bytes.Buffer
+	if err := encode(&got, img, encodeOptions{quality: 75, subsampling: subsamplings["420"]}); err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(&want, img, &jpeg.Options{Quality: 75}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want.Bytes()) {
+		t.Error("baseline 4:2:0 output differs from image/jpeg's")
+	}
+}
@@ -0,0 +1,161 @@
+package png
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+
+	"github.com/renja-g/convert/internal/converter"
+)
+
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// xmpKeyword is the keyword of the iTXt chunk that holds XMP.
+const xmpKeyword = "XML:com.adobe.xmp"
+
+// DecodeMetadata reads the metadata chunks of a PNG file, skipping over the
+// image data.
+func (d *pngDecoder) DecodeMetadata(r io.Reader) (*converter.Metadata, error) {
+	br := bufio.NewReader(r)
+	signature := make([]byte, len(pngSignature))
+	if _, err := io.ReadFull(br, signature); err != nil {
+		return nil, err
+	}
+	if string(signature) != pngSignature {
+		return nil, errors.New("png: not a PNG file")
+	}
+
+	md := &converter.Metadata{}
+	for {
+		// Each chunk is its length, type, data and a CRC.
+		var header [8]byte
+		if _, err := io.ReadFull(br, header[:]); err != nil {
+			return nil, err
+		}
+		if binary.BigEndian.Uint32(header[:4]) > 1<<31-1 {
+			return nil, errors.New("png: invalid chunk length")
+		}
+		length := int(binary.BigEndian.Uint32(header[:4]))
+		typ := string(header[4:])
+		switch typ {
+		case "IEND":
+			return md, nil
+		case "eXIf", "iCCP", "iTXt":
+		default:
+			if _, err := br.Discard(length + 4); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		data := make([]byte, length+4)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return nil, err
+		}
+		data = data[:length]
+		switch typ {
+		case "eXIf":
+			md.EXIF = data
+		case "iCCP":
+			// Broken profiles are ignored, like the decoders of most
+			// viewers do.
+			md.ICC, _ = readICCP(data)
+		case "iTXt":
+			if xmp, ok := readXMP(data); ok {
+				md.XMP = xmp
+			}
+		}
+	}
+}
+
+// readICCP returns the profile of an iCCP chunk: a name, the compression
+// method and the zlib compressed profile.
+func readICCP(data []byte) ([]byte, error) {
+	_, rest, ok := bytes.Cut(data, []byte{0})
+	if !ok || len(rest) < 1 || rest[0] != 0 {
+		return nil, errors.New("png: invalid iCCP chunk")
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(rest[1:]))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// readXMP returns the text of an iTXt chunk if it holds an XMP packet. The
+// keyword is followed by the compression flag and method, a language tag
+// and a translated keyword.
+func readXMP(data []byte) ([]byte, bool) {
+	keyword, rest, ok := bytes.Cut(data, []byte{0})
+	if !ok || string(keyword) != xmpKeyword || len(rest) < 2 {
+		return nil, false
+	}
+	compressed := rest[0] == 1
+	rest = rest[2:]
+	for i := 0; i < 2; i++ {
+		if _, rest, ok = bytes.Cut(rest, []byte{0}); !ok {
+			return nil, false
+		}
+	}
+	if !compressed {
+		return rest, true
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(rest))
+	if err != nil {
+		return nil, false
+	}
+	defer zr.Close()
+	text, err := io.ReadAll(zr)
+	return text, err == nil
+}
+
+// EmbedMetadata inserts the metadata chunks after the IHDR chunk, as the
+// ICC profile must precede the image data.
+func (e *pngEncoder) EmbedMetadata(data []byte, md *converter.Metadata, options converter.Options) ([]byte, error) {
+	// The signature is followed by IHDR, whose data is 13 bytes long.
+	const ihdrEnd = len(pngSignature) + 8 + 13 + 4
+	if len(data) < ihdrEnd || string(data[:len(pngSignature)]) != pngSignature || string(data[12:16]) != "IHDR" {
+		return nil, errors.New("png: missing IHDR chunk")
+	}
+
+	var chunks []byte
+	if md.ICC != nil {
+		var buf bytes.Buffer
+		buf.WriteString("ICC Profile\x00\x00")
+		zw := zlib.NewWriter(&buf)
+		if _, err := zw.Write(md.ICC); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		chunks = appendChunk(chunks, "iCCP", buf.Bytes())
+	}
+	if md.EXIF != nil {
+		chunks = appendChunk(chunks, "eXIf", md.EXIF)
+	}
+	if md.XMP != nil {
+		// Uncompressed, without language tag and translated keyword.
+		text := append([]byte(xmpKeyword+"\x00\x00\x00\x00\x00"), md.XMP...)
+		chunks = appendChunk(chunks, "iTXt", text)
+	}
+
+	out := make([]byte, 0, len(data)+len(chunks))
+	out = append(out, data[:ihdrEnd]...)
+	out = append(out, chunks...)
+	return append(out, data[ihdrEnd:]...), nil
+}
+
+// appendChunk appends a chunk with its length and CRC.
+func appendChunk(dst []byte, typ string, data []byte) []byte {
+	dst = binary.BigEndian.AppendUint32(dst, uint32(len(data)))
+	start := len(dst)
+	dst = append(dst, typ...)
+	dst = append(dst, data...)
+	return binary.BigEndian.AppendUint32(dst, crc32.ChecksumIEEE(dst[start:]))
+}
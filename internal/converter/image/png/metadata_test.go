@@ -0,0 +1,49 @@
+package png
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/renja-g/convert/internal/converter"
+)
+
+func TestMetadataRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 3, 2))); err != nil {
+		t.Fatal(err)
+	}
+	md := &converter.Metadata{
+		EXIF: []byte("II*\x00\x08\x00\x00\x00\x00\x00\x00\x00\x00\x00"),
+		XMP:  []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/"/>`),
+		ICC:  bytes.Repeat([]byte("icc profile "), 100),
+	}
+	data, err := (&pngEncoder{}).EmbedMetadata(buf.Bytes(), md, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := (&pngDecoder{}).DecodeMetadata(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.EXIF, md.EXIF) || !bytes.Equal(got.XMP, md.XMP) || !bytes.Equal(got.ICC, md.ICC) {
+		t.Errorf("DecodeMetadata = %q, want %q", got, md)
+	}
+	// The chunks must keep the file valid, with correct CRCs.
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decoding the file with metadata: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 3 || b.Dy() != 2 {
+		t.Errorf("size = %v, want 3 × 2", b.Size())
+	}
+}
+
+func TestEmbedMetadataInvalid(t *testing.T) {
+	md := &converter.Metadata{XMP: []byte("<x/>")}
+	if _, err := (&pngEncoder{}).EmbedMetadata([]byte("GIF89a"), md, nil); err == nil {
+		t.Error("EmbedMetadata accepted a file that is not a PNG")
+	}
+}
@@ -0,0 +1,44 @@
+package png
+
+import (
+	"image"
+	"image/png"
+	"io"
+
+	"github.com/renja-g/convert/internal/converter"
+	"github.com/spf13/pflag"
+)
+
+type pngDecoder struct{}
+
+func (d *pngDecoder) Format() string { return ".png" }
+
+func (d *pngDecoder) Decode(r io.Reader, options converter.Options) (image.Image, error) {
+	return png.Decode(r)
+}
+
+func (d *pngDecoder) DecodeConfig(r io.Reader) (image.Config, error) {
+	return png.DecodeConfig(r)
+}
+
+func (d *pngDecoder) GetFlags() *pflag.FlagSet {
+	return pflag.NewFlagSet("png", pflag.ExitOnError)
+}
+
+type pngEncoder struct{}
+
+func (e *pngEncoder) Format() string { return ".png" }
+
+func (e *pngEncoder) Encode(w io.Writer, img image.Image, options converter.Options) error {
+	return png.Encode(w, img)
+}
+
+func (e *pngEncoder) GetFlags() *pflag.FlagSet {
+	// No specific flags for PNG output
+	return pflag.NewFlagSet("png", pflag.ExitOnError)
+}
+
+func init() {
+	converter.RegisterDecoder(&pngDecoder{})
+	converter.RegisterEncoder(&pngEncoder{})
+}
@@ -0,0 +1,342 @@
+package webp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"time"
+
+	"github.com/renja-g/convert/internal/converter"
+
+	"github.com/kolesa-team/go-webp/decoder"
+	"github.com/kolesa-team/go-webp/webp"
+)
+
+// Animated WebP files use the extended format: a VP8X header with the
+// animation flag, an ANIM chunk with the loop count and one ANMF chunk per
+// frame. Each ANMF chunk wraps the same ALPH/VP8/VP8L chunks as a still
+// image, which is how frames are handed to and taken from libwebp.
+
+const (
+	vp8xFlagAnimation = 0x02
+	vp8xFlagAlpha     = 0x10
+
+	anmfFlagDispose = 0x01
+	anmfFlagNoBlend = 0x02
+)
+
+// chunk is a RIFF chunk.
+type chunk struct {
+	id   string
+	data []byte
+}
+
+// readChunks parses a sequence of RIFF chunks.
+func readChunks(data []byte) ([]chunk, error) {
+	var chunks []chunk
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return nil, errors.New("webp: truncated chunk header")
+		}
+		size := int(binary.LittleEndian.Uint32(data[4:8]))
+		if size > len(data)-8 {
+			return nil, errors.New("webp: truncated chunk")
+		}
+		chunks = append(chunks, chunk{id: string(data[:4]), data: data[8 : 8+size]})
+		// Chunks are padded to an even size.
+		data = data[min(8+size+size%2, len(data)):]
+	}
+	return chunks, nil
+}
+
+// readFile parses a WebP file into its chunks.
+func readFile(data []byte) ([]chunk, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errors.New("webp: not a WebP file")
+	}
+	return readChunks(data[12:])
+}
+
+func appendChunk(dst []byte, id string, data []byte) []byte {
+	dst = append(dst, id...)
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(len(data)))
+	dst = append(dst, data...)
+	if len(data)%2 == 1 {
+		dst = append(dst, 0)
+	}
+	return dst
+}
+
+func writeFile(w io.Writer, body []byte) error {
+	header := []byte("RIFF")
+	header = binary.LittleEndian.AppendUint32(header, uint32(4+len(body)))
+	header = append(header, "WEBP"...)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(body)
+	return err
+}
+
+func get24(b []byte) int {
+	return int(b[0]) | int(b[1])<<8 | int(b[2])<<16
+}
+
+func append24(dst []byte, v int) []byte {
+	return append(dst, byte(v), byte(v>>8), byte(v>>16))
+}
+
+// isAnimated reports whether data is an animated WebP file.
+func isAnimated(data []byte) bool {
+	return len(data) >= 21 && string(data[12:16]) == "VP8X" && data[20]&vp8xFlagAnimation != 0
+}
+
+// decodeConfig reads the canvas size from the first 30 bytes of a WebP
+// file, which hold the header of its first chunk. The colour model is
+// color.NRGBAModel for images with alpha, color.YCbCrModel for other lossy
+// images and color.RGBAModel for other lossless ones.
+func decodeConfig(header []byte) (image.Config, error) {
+	if len(header) < 30 || string(header[:4]) != "RIFF" || string(header[8:12]) != "WEBP" {
+		return image.Config{}, errors.New("webp: not a WebP file")
+	}
+	config := image.Config{ColorModel: color.NRGBAModel}
+	data := header[20:]
+	switch string(header[12:16]) {
+	case "VP8X":
+		config.Width = get24(data[4:]) + 1
+		config.Height = get24(data[7:]) + 1
+		if data[0]&vp8xFlagAlpha == 0 {
+			config.ColorModel = color.RGBAModel
+		}
+	case "VP8 ":
+		// A 3 byte frame tag and the start code precede the 14 bit sizes.
+		if data[3] != 0x9d || data[4] != 0x01 || data[5] != 0x2a {
+			return image.Config{}, errors.New("webp: invalid VP8 header")
+		}
+		config.Width = int(binary.LittleEndian.Uint16(data[6:])) & 0x3fff
+		config.Height = int(binary.LittleEndian.Uint16(data[8:])) & 0x3fff
+		config.ColorModel = color.YCbCrModel
+	case "VP8L":
+		// A signature byte precedes two 14 bit sizes, stored minus one,
+		// and the flag that tells whether alpha is used.
+		if data[0] != 0x2f {
+			return image.Config{}, errors.New("webp: invalid VP8L header")
+		}
+		bits := binary.LittleEndian.Uint32(data[1:])
+		config.Width = int(bits&0x3fff) + 1
+		config.Height = int(bits>>14&0x3fff) + 1
+		if bits&(1<<28) == 0 {
+			config.ColorModel = color.RGBAModel
+		}
+	default:
+		return image.Config{}, errors.New("webp: unknown bitstream format")
+	}
+	return config, nil
+}
+
+// demux splits an animated WebP file into frames and decodes them. The
+// frames must lie within the canvas, and their combined area is held to
+// the pixel limit before any of them is decoded: each frame is decoded in
+// full, so a small canvas does not bound the memory they take.
+func demux(data []byte, limits converter.Limits) (*converter.Animation, error) {
+	chunks, err := readFile(data)
+	if err != nil {
+		return nil, err
+	}
+
+	anim := &converter.Animation{}
+	var frames [][]byte
+	var area int64
+	for _, c := range chunks {
+		switch c.id {
+		case "VP8X":
+			if len(c.data) < 10 {
+				return nil, errors.New("webp: invalid VP8X chunk")
+			}
+			anim.Width = get24(c.data[4:]) + 1
+			anim.Height = get24(c.data[7:]) + 1
+		case "ANIM":
+			if len(c.data) < 6 {
+				return nil, errors.New("webp: invalid ANIM chunk")
+			}
+			anim.LoopCount = int(binary.LittleEndian.Uint16(c.data[4:]))
+		case "ANMF":
+			r, err := frameBounds(c.data)
+			if err != nil {
+				return nil, fmt.Errorf("webp: frame %d: %w", len(frames), err)
+			}
+			if !r.In(image.Rect(0, 0, anim.Width, anim.Height)) {
+				return nil, fmt.Errorf("webp: frame %d: %v lies outside the %dx%d canvas", len(frames), r, anim.Width, anim.Height)
+			}
+			area += int64(r.Dx()) * int64(r.Dy())
+			frames = append(frames, c.data)
+		}
+	}
+	if len(frames) == 0 {
+		return nil, errors.New("webp: animation has no frames")
+	}
+	if err := limits.CheckPixels(area); err != nil {
+		return nil, err
+	}
+
+	for i, data := range frames {
+		frame, err := decodeFrame(data)
+		if err != nil {
+			return nil, fmt.Errorf("webp: frame %d: %w", i, err)
+		}
+		anim.Frames = append(anim.Frames, frame)
+	}
+	return anim, nil
+}
+
+// frameBounds reads the position and size of a frame from the payload of
+// an ANMF chunk.
+func frameBounds(data []byte) (image.Rectangle, error) {
+	if len(data) < 16 {
+		return image.Rectangle{}, errors.New("invalid ANMF chunk")
+	}
+	x, y := 2*get24(data[0:]), 2*get24(data[3:])
+	return image.Rect(x, y, x+get24(data[6:])+1, y+get24(data[9:])+1), nil
+}
+
+// decodeFrame decodes the payload of an ANMF chunk.
+func decodeFrame(data []byte) (converter.Frame, error) {
+	bounds, err := frameBounds(data)
+	if err != nil {
+		return converter.Frame{}, err
+	}
+	width, height := bounds.Dx(), bounds.Dy()
+	duration := get24(data[12:])
+	flags := data[15]
+
+	chunks, err := readChunks(data[16:])
+	if err != nil {
+		return converter.Frame{}, err
+	}
+
+	// Rebuild a still WebP file from the frame's bitstream chunks.
+	var body []byte
+	for _, c := range chunks {
+		if c.id == "ALPH" {
+			vp8x := []byte{vp8xFlagAlpha, 0, 0, 0}
+			vp8x = append24(vp8x, width-1)
+			vp8x = append24(vp8x, height-1)
+			body = appendChunk(body, "VP8X", vp8x)
+			break
+		}
+	}
+	for _, c := range chunks {
+		if c.id == "ALPH" || c.id == "VP8 " || c.id == "VP8L" {
+			body = appendChunk(body, c.id, c.data)
+		}
+	}
+	var file bytes.Buffer
+	if err := writeFile(&file, body); err != nil {
+		return converter.Frame{}, err
+	}
+	// The bitstream must be as large as the frame, whose size has been
+	// checked against the canvas and the limits.
+	config, err := decodeConfig(file.Bytes())
+	if err != nil {
+		return converter.Frame{}, err
+	}
+	if config.Width != width || config.Height != height {
+		return converter.Frame{}, fmt.Errorf("bitstream is %dx%d, the frame %dx%d", config.Width, config.Height, width, height)
+	}
+
+	img, err := webp.Decode(&file, &decoder.Options{})
+	if err != nil {
+		return converter.Frame{}, err
+	}
+	positioned := image.NewNRGBA(bounds)
+	draw.Draw(positioned, positioned.Rect, img, img.Bounds().Min, draw.Src)
+
+	frame := converter.Frame{
+		Image: positioned,
+		Delay: time.Duration(duration) * time.Millisecond,
+		Blend: flags&anmfFlagNoBlend == 0,
+	}
+	if flags&anmfFlagDispose != 0 {
+		frame.Disposal = converter.DisposeBackground
+	}
+	return frame, nil
+}
+
+// mux encodes the frames of a with encodeFrame and writes an animated WebP
+// file. WebP frames must start at even coordinates and have no equivalent
+// of DisposePrevious, so if any frame needs either the whole animation is
+// written as full-canvas frames instead.
+func mux(w io.Writer, a *converter.Animation, encodeFrame func(io.Writer, image.Image) error) error {
+	frames := a.Frames
+	for _, f := range a.Frames {
+		origin := f.Image.Bounds().Min
+		if origin.X%2 != 0 || origin.Y%2 != 0 || f.Disposal == converter.DisposePrevious {
+			rendered := a.Render()
+			frames = make([]converter.Frame, len(rendered))
+			for i, img := range rendered {
+				frames[i] = converter.Frame{Image: img, Delay: a.Frames[i].Delay}
+			}
+			break
+		}
+	}
+
+	var body []byte
+	flags := byte(vp8xFlagAnimation)
+	for i, f := range frames {
+		b := f.Image.Bounds()
+		if !converter.IsOpaque(f.Image) {
+			flags |= vp8xFlagAlpha
+		}
+
+		// The encoder expects the image at the origin.
+		img := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(img, img.Rect, f.Image, b.Min, draw.Src)
+
+		var buf bytes.Buffer
+		if err := encodeFrame(&buf, img); err != nil {
+			return fmt.Errorf("webp: frame %d: %w", i, err)
+		}
+		chunks, err := readFile(buf.Bytes())
+		if err != nil {
+			return fmt.Errorf("webp: frame %d: %w", i, err)
+		}
+
+		anmf := append24(nil, b.Min.X/2)
+		anmf = append24(anmf, b.Min.Y/2)
+		anmf = append24(anmf, b.Dx()-1)
+		anmf = append24(anmf, b.Dy()-1)
+		anmf = append24(anmf, min(int(f.Delay/time.Millisecond), 1<<24-1))
+		var frameFlags byte
+		if !f.Blend {
+			frameFlags |= anmfFlagNoBlend
+		}
+		if f.Disposal == converter.DisposeBackground {
+			frameFlags |= anmfFlagDispose
+		}
+		anmf = append(anmf, frameFlags)
+		for _, c := range chunks {
+			if c.id == "ALPH" || c.id == "VP8 " || c.id == "VP8L" {
+				anmf = appendChunk(anmf, c.id, c.data)
+			}
+		}
+		body = appendChunk(body, "ANMF", anmf)
+	}
+
+	header := []byte{flags, 0, 0, 0}
+	header = append24(header, a.Width-1)
+	header = append24(header, a.Height-1)
+	out := appendChunk(nil, "VP8X", header)
+
+	// Transparent background colour (BGRA) and loop count.
+	animChunk := []byte{0, 0, 0, 0}
+	animChunk = binary.LittleEndian.AppendUint16(animChunk, uint16(min(a.LoopCount, 1<<16-1)))
+	out = appendChunk(out, "ANIM", animChunk)
+	out = append(out, body...)
+
+	return writeFile(w, out)
+}
@@ -0,0 +1,63 @@
+package webp
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/renja-g/convert/internal/converter"
+)
+
+// animation returns an animated WebP file with a width × height canvas
+// and a frame for each rectangle, given as x, y, w and h. The frames hold
+// no bitstream, as demux must reject these files before decoding them.
+func animation(t *testing.T, width, height int, frames ...[4]int) []byte {
+	t.Helper()
+	vp8x := []byte{vp8xFlagAnimation, 0, 0, 0}
+	vp8x = append24(vp8x, width-1)
+	vp8x = append24(vp8x, height-1)
+	body := appendChunk(nil, "VP8X", vp8x)
+	body = appendChunk(body, "ANIM", make([]byte, 6))
+	for _, f := range frames {
+		anmf := append24(nil, f[0]/2)
+		anmf = append24(anmf, f[1]/2)
+		anmf = append24(anmf, f[2]-1)
+		anmf = append24(anmf, f[3]-1)
+		anmf = append24(anmf, 100)
+		anmf = append(anmf, 0)
+		body = appendChunk(body, "ANMF", anmf)
+	}
+	var buf bytes.Buffer
+	if err := writeFile(&buf, body); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDemuxRejectsFramesOutsideCanvas(t *testing.T) {
+	tests := map[string][4]int{
+		"too large":    {0, 0, 16383, 16383},
+		"too wide":     {0, 0, 11, 10},
+		"offset right": {2, 0, 10, 10},
+		"offset down":  {0, 4, 8, 8},
+	}
+	for name, frame := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := demux(animation(t, 10, 10, frame), converter.Limits{})
+			if err == nil || !strings.Contains(err.Error(), "outside") {
+				t.Errorf("demux = %v, want an error for a frame outside the canvas", err)
+			}
+		})
+	}
+}
+
+func TestDemuxLimitsFrameArea(t *testing.T) {
+	// Each frame fits the canvas and the limit; together they exceed it.
+	data := animation(t, 100, 100, [4]int{0, 0, 100, 100}, [4]int{0, 0, 100, 100}, [4]int{0, 0, 100, 100})
+	_, err := demux(data, converter.Limits{MaxPixels: 25000})
+	var limitErr *converter.LimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != converter.LimitPixels || limitErr.Value != 30000 {
+		t.Errorf("demux = %v, want a pixel LimitError for 30000 pixels", err)
+	}
+}
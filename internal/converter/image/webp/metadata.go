@@ -0,0 +1,105 @@
+package webp
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"io"
+
+	"github.com/renja-g/convert/internal/converter"
+	"github.com/renja-g/convert/internal/exif"
+)
+
+// VP8X flags announcing metadata chunks.
+const (
+	vp8xFlagICC  = 0x20
+	vp8xFlagEXIF = 0x08
+	vp8xFlagXMP  = 0x04
+)
+
+// DecodeMetadata reads the metadata chunks of an extended WebP file.
+func (d *webpDecoder) DecodeMetadata(r io.Reader) (*converter.Metadata, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := readFile(data)
+	if err != nil {
+		return nil, err
+	}
+
+	md := &converter.Metadata{}
+	for _, c := range chunks {
+		switch c.id {
+		case "EXIF":
+			// Some writers keep the JPEG prefix, which WebP does not use.
+			md.EXIF = bytes.TrimPrefix(c.data, []byte(exif.Header))
+		case "XMP ":
+			md.XMP = c.data
+		case "ICCP":
+			md.ICC = c.data
+		}
+	}
+	return md, nil
+}
+
+// EmbedMetadata adds the metadata chunks to a WebP file, converting a
+// simple file to the extended format, which starts with a VP8X chunk that
+// announces them. The ICC profile follows the VP8X chunk, EXIF and XMP go
+// last.
+func (e *webpEncoder) EmbedMetadata(data []byte, md *converter.Metadata, options converter.Options) ([]byte, error) {
+	chunks, err := readFile(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 {
+		return nil, errors.New("webp: empty file")
+	}
+
+	var header []byte
+	if chunks[0].id == "VP8X" {
+		header = bytes.Clone(chunks[0].data)
+		chunks = chunks[1:]
+	} else {
+		config, err := decodeConfig(data)
+		if err != nil {
+			return nil, err
+		}
+		var flags byte
+		if config.ColorModel == color.NRGBAModel {
+			flags |= vp8xFlagAlpha
+		}
+		header = []byte{flags, 0, 0, 0}
+		header = append24(header, config.Width-1)
+		header = append24(header, config.Height-1)
+	}
+	if len(header) < 10 {
+		return nil, errors.New("webp: invalid VP8X chunk")
+	}
+
+	header[0] &^= vp8xFlagICC | vp8xFlagEXIF | vp8xFlagXMP
+	var body []byte
+	if md.ICC != nil {
+		header[0] |= vp8xFlagICC
+		body = appendChunk(body, "ICCP", md.ICC)
+	}
+	for _, c := range chunks {
+		if c.id != "ICCP" && c.id != "EXIF" && c.id != "XMP " {
+			body = appendChunk(body, c.id, c.data)
+		}
+	}
+	if md.EXIF != nil {
+		header[0] |= vp8xFlagEXIF
+		body = appendChunk(body, "EXIF", md.EXIF)
+	}
+	if md.XMP != nil {
+		header[0] |= vp8xFlagXMP
+		body = appendChunk(body, "XMP ", md.XMP)
+	}
+
+	var buf bytes.Buffer
+	if err := writeFile(&buf, append(appendChunk(nil, "VP8X", header), body...)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,148 @@
+package webp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"slices"
+	"testing"
+
+	"github.com/renja-g/convert/internal/converter"
+	_ "github.com/renja-g/convert/internal/converter/image/png"
+	"github.com/renja-g/convert/internal/exif"
+)
+
+// lossless returns a simple lossless WebP file of the given size. Only the
+// VP8L header is valid, which is all that embedding metadata reads.
+func lossless(t *testing.T, width, height int) []byte {
+	t.Helper()
+	vp8l := binary.LittleEndian.AppendUint32([]byte{0x2f}, uint32(width-1)|uint32(height-1)<<14)
+	vp8l = append(vp8l, make([]byte, 16)...)
+	var buf bytes.Buffer
+	if err := writeFile(&buf, appendChunk(nil, "VP8L", vp8l)); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// chunkIDs returns the IDs of the chunks of a WebP file.
+func chunkIDs(t *testing.T, data []byte) []string {
+	t.Helper()
+	chunks, err := readFile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, c := range chunks {
+		ids = append(ids, c.id)
+	}
+	return ids
+}
+
+func TestMetadataRoundTrip(t *testing.T) {
+	md := &converter.Metadata{
+		EXIF: []byte("II*\x00\x08\x00\x00\x00\x00\x00\x00\x00\x00\x00"),
+		XMP:  []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/"/>`),
+		ICC:  bytes.Repeat([]byte("icc profile "), 100),
+	}
+	data, err := (&webpEncoder{}).EmbedMetadata(lossless(t, 300, 200), md, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := chunkIDs(t, data), []string{"VP8X", "ICCP", "VP8L", "EXIF", "XMP "}; !slices.Equal(got, want) {
+		t.Fatalf("chunks = %q, want %q", got, want)
+	}
+	header := data[20:30]
+	if flags := header[0]; flags != vp8xFlagICC|vp8xFlagEXIF|vp8xFlagXMP {
+		t.Errorf("VP8X flags = %#x", flags)
+	}
+	if w, h := int(header[4])|int(header[5])<<8|int(header[6])<<16, int(header[7])|int(header[8])<<8|int(header[9])<<16; w != 299 || h != 199 {
+		t.Errorf("VP8X canvas = %d × %d, want 299 × 199", w, h)
+	}
+
+	got, err := (&webpDecoder{}).DecodeMetadata(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.EXIF, md.EXIF) || !bytes.Equal(got.XMP, md.XMP) || !bytes.Equal(got.ICC, md.ICC) {
+		t.Errorf("DecodeMetadata = %q, want %q", got, md)
+	}
+
+	// Embedding into an extended file replaces the metadata chunks.
+	data, err = (&webpEncoder{}).EmbedMetadata(data, &converter.Metadata{XMP: []byte("<x/>")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := chunkIDs(t, data), []string{"VP8X", "VP8L", "XMP "}; !slices.Equal(got, want) {
+		t.Errorf("chunks after embedding again = %q, want %q", got, want)
+	}
+	if flags := data[20]; flags != vp8xFlagXMP {
+		t.Errorf("VP8X flags after embedding again = %#x, want %#x", flags, vp8xFlagXMP)
+	}
+}
+
+// gpsEXIF returns an EXIF block holding the make and a GPS IFD with the
+// latitude reference.
+func gpsEXIF() []byte {
+	le := binary.LittleEndian
+	entry := func(b []byte, tag, typ uint16, count, value uint32) []byte {
+		b = le.AppendUint16(b, tag)
+		b = le.AppendUint16(b, typ)
+		b = le.AppendUint32(b, count)
+		return le.AppendUint32(b, value)
+	}
+	const gpsOffset = 8 + 2 + 2*12 + 4
+	b := le.AppendUint32([]byte("II*\x00"), 8)
+	b = le.AppendUint16(b, 2)
+	b = entry(b, exif.TagMake, 2, 4, 'A'|'B'<<8|'C'<<16)
+	b = entry(b, exif.TagGPSIFD, 4, 1, gpsOffset)
+	b = le.AppendUint32(b, 0)
+	b = le.AppendUint16(b, 1)
+	b = entry(b, 1, 2, 2, 'N') // latitude reference
+	return le.AppendUint32(b, 0)
+}
+
+// TestStripGPS converts a WebP file that records a location to PNG and
+// back, removing the location on the way back.
+func TestStripGPS(t *testing.T) {
+	convert := func(from, to string, input []byte, options converter.Options) []byte {
+		t.Helper()
+		c, ok := converter.GetConverter(from, to)
+		if !ok {
+			t.Fatalf("no converter from %s to %s", from, to)
+		}
+		var out bytes.Buffer
+		if err := c.ConvertStream(context.Background(), bytes.NewReader(input), &out, options); err != nil {
+			t.Fatal(err)
+		}
+		return out.Bytes()
+	}
+
+	var buf bytes.Buffer
+	if err := (&webpEncoder{}).Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+	input, err := (&webpEncoder{}).EmbedMetadata(buf.Bytes(), &converter.Metadata{EXIF: gpsEXIF()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	png := convert(".webp", ".png", input, nil)
+	out := convert(".png", ".webp", png, converter.Options{"strip-gps": true})
+
+	md, err := (&webpDecoder{}).DecodeMetadata(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, err := exif.Parse(md.EXIF)
+	if err != nil {
+		t.Fatalf("parsing the EXIF of the output: %v", err)
+	}
+	if x.HasGPS() {
+		t.Error("the EXIF of the output has a GPS IFD")
+	}
+	if s, _ := x.String(exif.TagMake); s != "ABC" {
+		t.Errorf("make = %q, want ABC", s)
+	}
+}
@@ -0,0 +1,212 @@
+package webp
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"math"
+
+	"github.com/renja-g/convert/internal/converter"
+
+	"github.com/kolesa-team/go-webp/decoder"
+	"github.com/kolesa-team/go-webp/encoder"
+	"github.com/kolesa-team/go-webp/webp"
+	"github.com/spf13/pflag"
+)
+
+// webpDecoder and webpEncoder use the go-webp library, which binds libwebp.
+// libwebp's simple API only handles still images, so animations are
+// (de)multiplexed in anim.go and each frame is coded separately.
+
+type webpDecoder struct{}
+
+func (d *webpDecoder) Format() string { return ".webp" }
+
+func (d *webpDecoder) Decode(r io.Reader, options converter.Options) (image.Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if isAnimated(data) {
+		anim, err := demux(data, options.Limits())
+		if err != nil {
+			return nil, err
+		}
+		return anim.Still(options)
+	}
+	return webp.Decode(bytes.NewReader(data), &decoder.Options{})
+}
+
+func (d *webpDecoder) DecodeAnimation(r io.Reader, options converter.Options) (*converter.Animation, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if isAnimated(data) {
+		return demux(data, options.Limits())
+	}
+
+	// A still image is an animation with a single frame.
+	img, err := webp.Decode(bytes.NewReader(data), &decoder.Options{})
+	if err != nil {
+		return nil, err
+	}
+	b := img.Bounds()
+	return &converter.Animation{
+		Width:  b.Dx(),
+		Height: b.Dy(),
+		Frames: []converter.Frame{{Image: img}},
+	}, nil
+}
+
+func (d *webpDecoder) DecodeConfig(r io.Reader) (image.Config, error) {
+	header := make([]byte, 30)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return image.Config{}, err
+	}
+	return decodeConfig(header)
+}
+
+func (d *webpDecoder) CountFrames(r io.Reader) (int, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	chunks, err := readFile(data)
+	if err != nil {
+		return 0, err
+	}
+	if !isAnimated(data) {
+		return 1, nil
+	}
+
+	frames := 0
+	for _, c := range chunks {
+		if c.id == "ANMF" {
+			frames++
+		}
+	}
+	return frames, nil
+}
+
+func (d *webpDecoder) GetFlags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("webp", pflag.ExitOnError)
+	flags.Int("frame", -1, "Extract a single frame (0-based) of an animation")
+	return flags
+}
+
+type webpEncoder struct{}
+
+func (e *webpEncoder) Format() string { return ".webp" }
+
+// Lossy reports that WebP compression loses detail unless --lossless is
+// given.
+func (e *webpEncoder) Lossy() bool { return true }
+
+var presets = map[string]encoder.EncodingPreset{
+	"default": encoder.PresetDefault,
+	"picture": encoder.PresetPicture,
+	"photo":   encoder.PresetPhoto,
+	"drawing": encoder.PresetDrawing,
+	"icon":    encoder.PresetIcon,
+	"text":    encoder.PresetText,
+}
+
+// encoderOptions builds the libwebp settings from the converter options. It
+// also returns the requested alpha quality, which go-webp does not expose.
+func encoderOptions(options converter.Options) (*encoder.Options, int, error) {
+	quality := options.Int("quality", 75)
+	if quality < 0 || quality > 100 {
+		return nil, 0, fmt.Errorf("webp: quality must be between 0 and 100, got %d", quality)
+	}
+	method := options.Int("method", 4)
+	if method < 0 || method > 6 {
+		return nil, 0, fmt.Errorf("webp: method must be between 0 and 6, got %d", method)
+	}
+	alphaQuality := options.Int("alpha-quality", 100)
+	if alphaQuality < 0 || alphaQuality > 100 {
+		return nil, 0, fmt.Errorf("webp: alpha quality must be between 0 and 100, got %d", alphaQuality)
+	}
+	name := options.String("preset", "default")
+	preset, ok := presets[name]
+	if !ok {
+		return nil, 0, fmt.Errorf("webp: unknown preset %q (want default, photo, picture, drawing, icon or text)", name)
+	}
+
+	encOptions, err := encoder.NewLossyEncoderOptions(preset, float32(quality))
+	if err != nil {
+		return nil, 0, err
+	}
+	// As with cwebp, quality and method trade size for effort in lossless mode.
+	encOptions.Lossless = options.Bool("lossless", false)
+	encOptions.Method = method
+	return encOptions, alphaQuality, nil
+}
+
+// encodeImage encodes a single image with the given settings.
+func encodeImage(w io.Writer, img image.Image, encOptions *encoder.Options, alphaQuality int) error {
+	if !encOptions.Lossless && alphaQuality < 100 {
+		img = reduceAlphaLevels(img, alphaQuality)
+	}
+	return webp.Encode(w, img, encOptions)
+}
+
+func (e *webpEncoder) Encode(w io.Writer, img image.Image, options converter.Options) error {
+	encOptions, alphaQuality, err := encoderOptions(options)
+	if err != nil {
+		return err
+	}
+	return encodeImage(w, img, encOptions, alphaQuality)
+}
+
+func (e *webpEncoder) EncodeAnimation(w io.Writer, a *converter.Animation, options converter.Options) error {
+	encOptions, alphaQuality, err := encoderOptions(options)
+	if err != nil {
+		return err
+	}
+	if len(a.Frames) == 1 {
+		return encodeImage(w, a.Frames[0].Image, encOptions, alphaQuality)
+	}
+	return mux(w, a, func(w io.Writer, img image.Image) error {
+		return encodeImage(w, img, encOptions, alphaQuality)
+	})
+}
+
+func (e *webpEncoder) GetFlags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("webp", pflag.ExitOnError)
+	flags.Int("quality", 75, "WebP quality (0-100); compression effort when lossless")
+	flags.Bool("lossless", false, "Encode lossless WebP")
+	flags.String("preset", "default", "WebP preset: default, photo, picture, drawing, icon or text")
+	flags.Int("method", 4, "WebP compression method (0=fast, 6=slowest)")
+	flags.Int("alpha-quality", 100, "WebP alpha channel quality (0-100)")
+	return flags
+}
+
+// reduceAlphaLevels quantizes the alpha channel the way libwebp does for
+// alpha_quality < 100. go-webp does not expose that setting, so the levels
+// are reduced before the image is handed to the encoder.
+func reduceAlphaLevels(img image.Image, quality int) image.Image {
+	levels := 16 + (quality-70)*8
+	if quality <= 70 {
+		levels = 2 + quality/5
+	}
+
+	b := img.Bounds()
+	dst := image.NewNRGBA(b)
+	draw.Draw(dst, b, img, b.Min, draw.Src)
+
+	step := 255.0 / float64(levels-1)
+	for i := 3; i < len(dst.Pix); i += 4 {
+		dst.Pix[i] = uint8(math.Round(math.Round(float64(dst.Pix[i])/step) * step))
+	}
+	return dst
+}
+
+func init() {
+	converter.RegisterDecoder(&webpDecoder{})
+	converter.RegisterEncoder(&webpEncoder{})
+}
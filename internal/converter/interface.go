@@ -1,18 +1,113 @@
 package converter
 
-import "github.com/spf13/pflag"
+import (
+	"context"
+	"image"
+	"io"
 
-// Options contains common conversion options.
-type Options map[string]interface{}
+	"github.com/spf13/pflag"
+)
+
+// Options contains common conversion options, keyed by flag name. Values
+// set by the program rather than by flags, such as the limits, are kept
+// under unexported key types, so they cannot be mistaken for options.
+type Options map[interface{}]interface{}
+
+// internalKey is the type of the keys of the values that are not options.
+type internalKey int
+
+const (
+	// warningKey holds the WarningFunc.
+	warningKey internalKey = iota
+	// limitsKey holds the Limits.
+	limitsKey
+)
 
 // Converter defines the contract for any file converter.
+// Converters are shared between concurrent conversions, so implementations
+// must be safe for concurrent use; decoders and encoders likewise.
 type Converter interface {
 	// From returns the source file extension (e.g., ".jpeg").
 	From() string
 	// To returns the destination file extension (e.g., ".png").
 	To() string
-	// Convert performs the file conversion.
-	Convert(inputPath string, outputPath string, options Options) error
+	// Convert performs the file conversion. If ctx is cancelled, the
+	// conversion stops and the partially written output is removed.
+	Convert(ctx context.Context, inputPath string, outputPath string, options Options) error
+	// ConvertStream reads the source format from r and writes the destination format to w.
+	// It returns ctx's error if ctx is cancelled before the conversion completes.
+	ConvertStream(ctx context.Context, r io.Reader, w io.Writer, options Options) error
 	// GetFlags returns a set of `pflag.FlagSet` for this converter's specific options.
 	GetFlags() *pflag.FlagSet
 }
+
+// Decoder reads a raster format into an image.Image.
+type Decoder interface {
+	// Format returns the file extension this decoder reads (e.g., ".png").
+	Format() string
+	// Decode reads an image from r.
+	Decode(r io.Reader, options Options) (image.Image, error)
+	// GetFlags returns a set of `pflag.FlagSet` for this decoder's specific options.
+	GetFlags() *pflag.FlagSet
+}
+
+// ConfigDecoder is implemented by decoders that can read the dimensions of
+// an image without decoding all of it.
+type ConfigDecoder interface {
+	// DecodeConfig reads the image header from r. For animations it
+	// describes the canvas.
+	DecodeConfig(r io.Reader) (image.Config, error)
+}
+
+// FrameCounter is implemented by decoders of formats that can hold
+// animations and can count the frames without decoding them.
+type FrameCounter interface {
+	// CountFrames returns the number of frames of the image in r.
+	CountFrames(r io.Reader) (int, error)
+}
+
+// MetadataDecoder is implemented by decoders of formats that can embed
+// metadata.
+type MetadataDecoder interface {
+	// DecodeMetadata reads the metadata embedded in the image in r.
+	DecodeMetadata(r io.Reader) (*Metadata, error)
+}
+
+// Encoder writes an image.Image in a raster format.
+type Encoder interface {
+	// Format returns the file extension this encoder writes (e.g., ".webp").
+	Format() string
+	// Encode writes img to w.
+	Encode(w io.Writer, img image.Image, options Options) error
+	// GetFlags returns a set of `pflag.FlagSet` for this encoder's specific options.
+	GetFlags() *pflag.FlagSet
+}
+
+// MetadataEncoder is implemented by encoders of formats that can embed
+// metadata.
+type MetadataEncoder interface {
+	// EmbedMetadata returns data, a file written by the encoder, with md
+	// embedded in it.
+	EmbedMetadata(data []byte, md *Metadata, options Options) ([]byte, error)
+}
+
+// LossyEncoder is implemented by encoders that lose image information
+// with their default options, such as JPEG. Conversion chains avoid them
+// for intermediate steps.
+type LossyEncoder interface {
+	Lossy() bool
+}
+
+// AnimationDecoder is implemented by decoders of formats that can hold
+// animations.
+type AnimationDecoder interface {
+	// DecodeAnimation reads all frames from r.
+	DecodeAnimation(r io.Reader, options Options) (*Animation, error)
+}
+
+// AnimationEncoder is implemented by encoders of formats that can hold
+// animations.
+type AnimationEncoder interface {
+	// EncodeAnimation writes all frames of a to w.
+	EncodeAnimation(w io.Writer, a *Animation, options Options) error
+}
@@ -0,0 +1,158 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Limits bound the resources a conversion may use, to protect against
+// decompression bombs: small files that decode to huge images. A zero
+// value means no limit.
+type Limits struct {
+	// MaxPixels is the largest width × height of an image or animation
+	// canvas. Decoders that can add up the areas of an animation's frames
+	// before decoding them hold the sum to it as well.
+	MaxPixels int64
+	// MaxInputBytes is the largest input that is read.
+	MaxInputBytes int64
+	// MaxFrames is the largest number of frames of an animation.
+	MaxFrames int
+}
+
+// The limits named by LimitError.
+const (
+	LimitPixels     = "pixels"
+	LimitInputBytes = "input bytes"
+	LimitFrames     = "frames"
+)
+
+// LimitError reports that an input exceeds one of the Limits. The limits
+// are checked before the image is decoded where the format allows.
+type LimitError struct {
+	// Limit is LimitPixels, LimitInputBytes or LimitFrames.
+	Limit string
+	// Value is the input's value, or for input bytes a lower bound of it,
+	// as reading stops at the limit.
+	Value, Max int64
+}
+
+func (e *LimitError) Error() string {
+	if e.Limit == LimitInputBytes {
+		return fmt.Sprintf("input is larger than the limit of %d bytes", e.Max)
+	}
+	return fmt.Sprintf("input has %d %s, more than the limit of %d", e.Value, e.Limit, e.Max)
+}
+
+// WithLimits returns a copy of o that enforces l.
+func (o Options) WithLimits(l Limits) Options {
+	options := make(Options, len(o)+1)
+	for k, v := range o {
+		options[k] = v
+	}
+	options[limitsKey] = l
+	return options
+}
+
+// Limits returns the limits set with WithLimits.
+func (o Options) Limits() Limits {
+	l, _ := o[limitsKey].(Limits)
+	return l
+}
+
+// check returns a LimitError if value exceeds max.
+func check(limit string, value, max int64) error {
+	if max > 0 && value > max {
+		return &LimitError{Limit: limit, Value: value, Max: max}
+	}
+	return nil
+}
+
+// CheckPixels returns a LimitError if pixels exceeds MaxPixels. It is for
+// decoders that can count pixels up front which checkHeader does not see,
+// such as the frames of an animation.
+func (l Limits) CheckPixels(pixels int64) error {
+	return check(LimitPixels, pixels, l.MaxPixels)
+}
+
+// limitReader fails with a LimitError once more than max bytes are read.
+type limitReader struct {
+	r        io.Reader
+	read     int64
+	max      int64
+	exceeded bool
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.max {
+		l.exceeded = true
+		return n, l.err()
+	}
+	return n, err
+}
+
+func (l *limitReader) err() error {
+	return &LimitError{Limit: LimitInputBytes, Value: l.read, Max: l.max}
+}
+
+// checkHeader enforces the pixel and frame limits on the input of d before
+// it is decoded, if d can read them up front. The returned reader yields
+// the complete input again. Counting frames may read the whole input into
+// memory, which is only done if a frame limit is set.
+func checkHeader(d Decoder, r io.Reader, limits Limits) (io.Reader, error) {
+	var err error
+	if cd, ok := d.(ConfigDecoder); ok && limits.MaxPixels > 0 {
+		r, err = peek(r, func(r io.Reader) error {
+			config, err := cd.DecodeConfig(r)
+			if err != nil {
+				return err
+			}
+			return check(LimitPixels, int64(config.Width)*int64(config.Height), limits.MaxPixels)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if fc, ok := d.(FrameCounter); ok && limits.MaxFrames > 0 {
+		r, err = peek(r, func(r io.Reader) error {
+			frames, err := fc.CountFrames(r)
+			if err != nil {
+				return err
+			}
+			return check(LimitFrames, int64(frames), int64(limits.MaxFrames))
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// checkDecoded enforces the pixel and frame limits on a decoded image, for
+// decoders that cannot check them up front.
+func checkDecoded(width, height, frames int, limits Limits) error {
+	if err := check(LimitPixels, int64(width)*int64(height), limits.MaxPixels); err != nil {
+		return err
+	}
+	return check(LimitFrames, int64(frames), int64(limits.MaxFrames))
+}
+
+// checkTransformed enforces the pixel limit on the result of applying t to
+// an image of width × height, so that enlarging cannot get around it.
+func checkTransformed(t *transform, width, height int, limits Limits) error {
+	w, h, err := t.size(width, height)
+	if err != nil {
+		return err
+	}
+	return check(LimitPixels, int64(w)*int64(h), limits.MaxPixels)
+}
+
+// peek calls fn with r and returns a reader that yields what fn read
+// followed by the rest of r.
+func peek(r io.Reader, fn func(io.Reader) error) (io.Reader, error) {
+	var buf bytes.Buffer
+	err := fn(io.TeeReader(r, &buf))
+	return io.MultiReader(&buf, r), err
+}
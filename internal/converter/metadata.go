@@ -0,0 +1,164 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/renja-g/convert/internal/exif"
+	"github.com/spf13/pflag"
+)
+
+// Metadata holds the metadata blocks embedded in an image file.
+type Metadata struct {
+	// EXIF is the TIFF structured EXIF block, without the "Exif\0\0"
+	// prefix of JPEG files.
+	EXIF []byte
+	// XMP is the XMP packet, an XML document.
+	XMP []byte
+	// ICC is the ICC colour profile.
+	ICC []byte
+}
+
+// metadataFlags returns the flags that control which metadata raster
+// converters copy from the input to the output.
+func metadataFlags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("metadata", pflag.ExitOnError)
+	flags.Bool("strip-metadata", false, "Remove EXIF, XMP and ICC metadata instead of copying it to the output")
+	flags.StringSlice("keep", nil, "Keep only these metadata: exif, xmp, icc and copyright (the EXIF artist and copyright)")
+	flags.Bool("strip-gps", false, "Remove the location from EXIF and XMP metadata")
+	return flags
+}
+
+// Metadata kept by --keep.
+const (
+	keepEXIF      = "exif"
+	keepXMP       = "xmp"
+	keepICC       = "icc"
+	keepCopyright = "copyright"
+)
+
+// empty reports whether m holds no metadata.
+func (m *Metadata) empty() bool {
+	return m == nil || m.EXIF == nil && m.XMP == nil && m.ICC == nil
+}
+
+// names lists the blocks m holds, for messages.
+func (m *Metadata) names() string {
+	var names []string
+	for _, b := range []struct {
+		name string
+		data []byte
+	}{{"EXIF", m.EXIF}, {"XMP", m.XMP}, {"ICC", m.ICC}} {
+		if b.data != nil {
+			names = append(names, b.name)
+		}
+	}
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
+
+// metadataPolicy holds the --strip-metadata, --keep and --strip-gps
+// options. By default all metadata is copied.
+type metadataPolicy struct {
+	// strip removes all metadata but the kinds in keep.
+	strip    bool
+	keep     map[string]bool
+	stripGPS bool
+}
+
+func newMetadataPolicy(options Options) (*metadataPolicy, error) {
+	p := &metadataPolicy{
+		strip:    options.Bool("strip-metadata", false),
+		keep:     make(map[string]bool),
+		stripGPS: options.Bool("strip-gps", false),
+	}
+	for _, k := range options.StringSlice("keep", nil) {
+		k = strings.ToLower(strings.TrimSpace(k))
+		switch k {
+		case keepEXIF, keepXMP, keepICC, keepCopyright:
+			p.keep[k] = true
+		default:
+			return nil, fmt.Errorf("unknown --keep %q, use exif, xmp, icc or copyright", k)
+		}
+	}
+	// Naming what to keep implies dropping the rest.
+	if len(p.keep) > 0 {
+		p.strip = true
+	}
+	return p, nil
+}
+
+// keeps reports whether metadata of the given kind is copied.
+func (p *metadataPolicy) keeps(kind string) bool {
+	return !p.strip || p.keep[kind]
+}
+
+// copiesAny reports whether any metadata is copied.
+func (p *metadataPolicy) copiesAny() bool {
+	return !p.strip || len(p.keep) > 0
+}
+
+// filter returns the metadata to write to the output: md without the
+// blocks and tags that are removed, and with the orientation reset if the
+// pixels have been oriented. EXIF blocks that cannot be edited are dropped,
+// as they might otherwise keep a location or turn the image twice.
+func (p *metadataPolicy) filter(md *Metadata, oriented bool, options Options) *Metadata {
+	if md == nil {
+		return nil
+	}
+	out := &Metadata{}
+	if p.keeps(keepICC) {
+		out.ICC = md.ICC
+	}
+
+	if p.keeps(keepXMP) && md.XMP != nil {
+		out.XMP = md.XMP
+		if p.stripGPS {
+			out.XMP = stripXMPGPS(out.XMP)
+		}
+		if oriented {
+			out.XMP = resetXMPOrientation(out.XMP)
+		}
+	}
+
+	if md.EXIF == nil {
+		return out
+	}
+	var err error
+	switch {
+	case p.keeps(keepEXIF):
+		out.EXIF = md.EXIF
+	case p.keep[keepCopyright]:
+		out.EXIF, err = exif.Copyright(md.EXIF)
+	}
+	if err == nil && out.EXIF != nil && p.stripGPS {
+		out.EXIF, err = exif.StripGPS(out.EXIF)
+	}
+	if err == nil && out.EXIF != nil && oriented {
+		out.EXIF, err = exif.ResetOrientation(out.EXIF)
+	}
+	if err != nil {
+		options.Warn("dropping the EXIF metadata: %v", err)
+		out.EXIF = nil
+	}
+	return out
+}
+
+// XMP stores properties either as attributes or as elements.
+var (
+	xmpGPS         = regexp.MustCompile(`(?s)\s+exif:GPS\w+="[^"]*"|<exif:GPS\w+[^>]*/>|<exif:GPS\w+[^>]*>.*?</exif:GPS\w+>`)
+	xmpOrientation = regexp.MustCompile(`(tiff:Orientation="|<tiff:Orientation>)\d(["<])`)
+)
+
+// stripXMPGPS removes the GPS properties from an XMP packet.
+func stripXMPGPS(xmp []byte) []byte {
+	return xmpGPS.ReplaceAll(xmp, nil)
+}
+
+// resetXMPOrientation sets the orientation property of an XMP packet to 1.
+func resetXMPOrientation(xmp []byte) []byte {
+	return xmpOrientation.ReplaceAll(xmp, []byte("${1}1${2}"))
+}
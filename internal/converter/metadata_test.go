@@ -0,0 +1,136 @@
+package converter_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image/jpeg"
+	"strings"
+	"testing"
+
+	"github.com/renja-g/convert/internal/converter"
+	"github.com/renja-g/convert/internal/exif"
+)
+
+// gpsEXIF returns an EXIF block holding the make, orientation 6 and a GPS
+// IFD with the latitude 52°31'N.
+func gpsEXIF() []byte {
+	le := binary.LittleEndian
+	entry := func(b []byte, tag, typ uint16, count, value uint32) []byte {
+		b = le.AppendUint16(b, tag)
+		b = le.AppendUint16(b, typ)
+		b = le.AppendUint32(b, count)
+		return le.AppendUint32(b, value)
+	}
+	const makeOffset = 8 + 2 + 3*12 + 4
+	const gpsOffset = makeOffset + 6
+	const latOffset = gpsOffset + 2 + 2*12 + 4
+
+	b := le.AppendUint32([]byte("II*\x00"), 8)
+	b = le.AppendUint16(b, 3)
+	b = entry(b, exif.TagMake, 2, 6, makeOffset)
+	b = entry(b, exif.TagOrientation, 3, 1, 6)
+	b = entry(b, exif.TagGPSIFD, 4, 1, gpsOffset)
+	b = le.AppendUint32(b, 0)
+	b = append(b, "Canon\x00"...)
+
+	b = le.AppendUint16(b, 2)
+	b = entry(b, 1, 2, 2, 'N') // latitude reference
+	b = entry(b, 2, 5, 3, latOffset)
+	b = le.AppendUint32(b, 0)
+	for _, v := range []uint32{52, 1, 31, 1, 0, 1} {
+		b = le.AppendUint32(b, v)
+	}
+	return b
+}
+
+const gpsXMP = `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` +
+	`<rdf:Description xmlns:exif="http://ns.adobe.com/exif/1.0/" xmlns:xmp="http://ns.adobe.com/xap/1.0/"` +
+	` xmp:CreatorTool="Camera" exif:GPSLatitude="52,31.0N"/></rdf:RDF></x:xmpmeta>`
+
+// jpegWithGPS returns a JPEG file whose EXIF and XMP record a location.
+func jpegWithGPS(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(40, 30, 1), nil); err != nil {
+		t.Fatal(err)
+	}
+	var segments []byte
+	for _, payload := range [][]byte{
+		append([]byte(exif.Header), gpsEXIF()...),
+		append([]byte("http://ns.adobe.com/xap/1.0/\x00"), gpsXMP...),
+	} {
+		segments = append(segments, 0xff, 0xe1)
+		segments = binary.BigEndian.AppendUint16(segments, uint16(2+len(payload)))
+		segments = append(segments, payload...)
+	}
+	return append(append(buf.Bytes()[:2:2], segments...), buf.Bytes()[2:]...)
+}
+
+// convert runs the conversion from one format to another on input.
+func convert(t *testing.T, from, to string, input []byte, options converter.Options) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	if err := getConverter(t, from, to).ConvertStream(context.Background(), bytes.NewReader(input), &out, options); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func TestStripGPS(t *testing.T) {
+	// The PNG input gets the metadata of the JPEG one, as the location is
+	// kept by default.
+	inputs := map[string][]byte{".jpeg": jpegWithGPS(t)}
+	inputs[".png"] = convert(t, ".jpeg", ".png", inputs[".jpeg"], converter.Options{"auto-orient": false})
+
+	for _, c := range []struct{ from, to string }{{".jpeg", ".png"}, {".png", ".jpeg"}} {
+		t.Run(c.from+" to "+c.to, func(t *testing.T) {
+			out := convert(t, c.from, c.to, inputs[c.from], converter.Options{"strip-gps": true})
+			md, err := converter.DecodeMetadata(bytes.NewReader(out), c.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			x, err := exif.Parse(md.EXIF)
+			if err != nil {
+				t.Fatalf("parsing the EXIF of the output: %v", err)
+			}
+			if x.HasGPS() {
+				t.Error("the EXIF of the output has a GPS IFD")
+			}
+			if s, _ := x.String(exif.TagMake); s != "Canon" {
+				t.Errorf("make = %q, want Canon", s)
+			}
+			// The pixels have been rotated, so the orientation is reset.
+			if o := x.Orientation(); o != 1 {
+				t.Errorf("orientation = %d, want 1", o)
+			}
+
+			xmp := string(md.XMP)
+			if strings.Contains(xmp, "GPS") {
+				t.Errorf("the XMP of the output has a location: %s", xmp)
+			}
+			if !strings.Contains(xmp, `xmp:CreatorTool="Camera"`) {
+				t.Errorf("the XMP of the output lost its other properties: %s", xmp)
+			}
+		})
+	}
+}
+
+func TestKeepGPS(t *testing.T) {
+	out := convert(t, ".jpeg", ".png", jpegWithGPS(t), nil)
+	md, err := converter.DecodeMetadata(bytes.NewReader(out), ".png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, err := exif.Parse(md.EXIF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lat, _, _ := x.GPS(); !x.HasGPS() || lat < 52.51 || lat > 52.52 {
+		t.Errorf("the location was not copied: latitude %v", lat)
+	}
+	if !strings.Contains(string(md.XMP), "exif:GPSLatitude") {
+		t.Errorf("the XMP location was not copied: %s", md.XMP)
+	}
+}
@@ -0,0 +1,190 @@
+package converter
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NameTemplate is an output file name template such as
+// "{dir}/out/{stem}-{width}x{height}.{ext}". Placeholders name a field of
+// the conversion (see Expand) or a converter option such as {quality}; "{{"
+// and "}}" stand for literal braces.
+type NameTemplate struct {
+	text string
+	// literals surround the fields: literals[i] precedes fields[i].
+	literals []string
+	fields   []string
+}
+
+// ParseNameTemplate parses an output file name template.
+func ParseNameTemplate(text string) (*NameTemplate, error) {
+	t := &NameTemplate{text: text}
+	var literal strings.Builder
+	for s := text; s != ""; {
+		i := strings.IndexAny(s, "{}")
+		if i < 0 {
+			literal.WriteString(s)
+			break
+		}
+		literal.WriteString(s[:i])
+		if i+1 < len(s) && s[i+1] == s[i] {
+			literal.WriteByte(s[i])
+			s = s[i+2:]
+			continue
+		}
+		if s[i] == '}' {
+			return nil, fmt.Errorf("name template %q: unexpected }", text)
+		}
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("name template %q: missing }", text)
+		}
+		field := s[i+1 : i+end]
+		if field == "" {
+			return nil, fmt.Errorf("name template %q: empty placeholder", text)
+		}
+		t.literals = append(t.literals, literal.String())
+		t.fields = append(t.fields, field)
+		literal.Reset()
+		s = s[i+end+1:]
+	}
+	t.literals = append(t.literals, literal.String())
+	return t, nil
+}
+
+// String returns the template text.
+func (t *NameTemplate) String() string { return t.text }
+
+// NameSource describes the conversion an output name is made for.
+type NameSource struct {
+	// Path is the input file, or "-" for stdin.
+	Path string
+	// From and To are the source and destination formats (e.g. ".png").
+	From, To string
+	// Dir is the directory the output would be written to by default.
+	Dir string
+	// Counter numbers the inputs of a run, starting at 1.
+	Counter int
+	// Time is when the run started.
+	Time    time.Time
+	Options Options
+}
+
+// Expand fills in the placeholders of t for src. The available fields are:
+//
+//	{dir}     the default output directory
+//	{stem}    the input file name without its extension
+//	{ext}     the destination format, e.g. "webp"
+//	{from}    the source format, e.g. "png"
+//	{width}   the width of the output image
+//	{height}  the height of the output image
+//	{n}       the counter, optionally zero-padded, e.g. {n:3} for "007"
+//	{date}    the date of the run, e.g. "2024-05-01"
+//	{time}    the time of the run, e.g. "153000"
+//
+// The output size is that of the input after the transforms in
+// src.Options, including the EXIF orientation unless auto-orient is off.
+// Any other placeholder is replaced by the converter option of that name.
+func (t *NameTemplate) Expand(src NameSource) (string, error) {
+	var size *[2]int
+	dimension := func(i int) (string, error) {
+		if size == nil {
+			w, h, err := outputSize(src)
+			if err != nil {
+				return "", fmt.Errorf("name template: %w", err)
+			}
+			size = &[2]int{w, h}
+		}
+		return strconv.Itoa(size[i]), nil
+	}
+
+	var sb strings.Builder
+	for i, field := range t.fields {
+		sb.WriteString(t.literals[i])
+
+		var value string
+		var err error
+		name, arg, _ := strings.Cut(field, ":")
+		switch name {
+		case "dir":
+			value = src.Dir
+		case "stem":
+			value = "stdin"
+			if src.Path != "-" {
+				base := filepath.Base(src.Path)
+				value = strings.TrimSuffix(base, filepath.Ext(base))
+			}
+		case "ext":
+			value = strings.TrimPrefix(src.To, ".")
+		case "from":
+			value = strings.TrimPrefix(src.From, ".")
+		case "width":
+			value, err = dimension(0)
+		case "height":
+			value, err = dimension(1)
+		case "n":
+			value = strconv.Itoa(src.Counter)
+			if arg != "" {
+				width, convErr := strconv.Atoi(arg)
+				if convErr != nil || width < 1 {
+					return "", fmt.Errorf("name template %q: invalid counter width %q", t.text, arg)
+				}
+				value = fmt.Sprintf("%0*d", width, src.Counter)
+			}
+		case "date":
+			value = src.Time.Format("2006-01-02")
+		case "time":
+			value = src.Time.Format("150405")
+		default:
+			v, ok := src.Options[field]
+			if !ok {
+				return "", fmt.Errorf("name template %q: unknown placeholder {%s}", t.text, field)
+			}
+			value = fmt.Sprint(v)
+		}
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(value)
+	}
+	sb.WriteString(t.literals[len(t.fields)])
+	return filepath.Clean(filepath.FromSlash(sb.String())), nil
+}
+
+// outputSize reads the dimensions of the input image and returns them as
+// they are after the transforms.
+func outputSize(src NameSource) (int, int, error) {
+	if src.Path == "-" {
+		return 0, 0, fmt.Errorf("the image size is not available when reading from stdin")
+	}
+	t, err := newTransform(src.Options)
+	if err != nil {
+		return 0, 0, err
+	}
+	f, err := os.Open(src.Path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	config, err := DecodeConfig(f, src.From)
+	if err != nil {
+		return 0, 0, err
+	}
+	if t.autoOrient {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return 0, 0, err
+		}
+		md, err := DecodeMetadata(f, src.From)
+		if err != nil {
+			return 0, 0, err
+		}
+		t.setOrientation(md)
+	}
+	return t.size(config.Width, config.Height)
+}
@@ -0,0 +1,139 @@
+package converter_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/renja-g/convert/internal/converter"
+)
+
+func TestParseNameTemplate(t *testing.T) {
+	tests := []struct {
+		text string
+		err  string
+	}{
+		{text: "{dir}/{stem}.{ext}"},
+		{text: "plain.png"},
+		{text: "{{literal}}-{n:3}.{ext}"},
+		{text: ""},
+		{text: "{stem", err: "missing }"},
+		{text: "stem}", err: "unexpected }"},
+		{text: "{}.png", err: "empty placeholder"},
+		{text: "{stem}}", err: "unexpected }"},
+	}
+	for _, tt := range tests {
+		tmpl, err := converter.ParseNameTemplate(tt.text)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("ParseNameTemplate(%q) = %v", tt.text, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("ParseNameTemplate(%q) = %v, want an error containing %q", tt.text, err, tt.err)
+		case err == nil && tmpl.String() != tt.text:
+			t.Errorf("String() = %q, want %q", tmpl.String(), tt.text)
+		}
+	}
+}
+
+// exifOrientation returns a little-endian EXIF block that holds only the
+// given orientation.
+func exifOrientation(orientation uint16) []byte {
+	le := binary.LittleEndian
+	b := le.AppendUint32([]byte("II*\x00"), 8)
+	b = le.AppendUint16(b, 1)
+	b = le.AppendUint16(b, 0x0112) // orientation
+	b = le.AppendUint16(b, 3)      // short
+	b = le.AppendUint32(b, 1)
+	b = le.AppendUint16(b, orientation)
+	b = le.AppendUint16(b, 0)
+	return le.AppendUint32(b, 0)
+}
+
+// writeJPEG writes a w × h JPEG file whose EXIF block holds orientation.
+func writeJPEG(t *testing.T, path string, w, h int, orientation uint16) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(w, h, 1), nil); err != nil {
+		t.Fatal(err)
+	}
+	payload := append([]byte("Exif\x00\x00"), exifOrientation(orientation)...)
+	segment := binary.BigEndian.AppendUint16([]byte{0xff, 0xe1}, uint16(2+len(payload)))
+	data := append(buf.Bytes()[:2:2], append(segment, payload...)...)
+	data = append(data, buf.Bytes()[2:]...)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNameTemplateExpand(t *testing.T) {
+	dir := t.TempDir()
+	photo := filepath.Join(dir, "photo.png")
+	if err := os.WriteFile(photo, encodePNG(t, testImage(40, 30, 1)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Orientation 6 is displayed rotated by 90°, orientation 3 by 180°.
+	rotated := filepath.Join(dir, "rotated.jpeg")
+	writeJPEG(t, rotated, 40, 30, 6)
+	upsideDown := filepath.Join(dir, "upside-down.jpeg")
+	writeJPEG(t, upsideDown, 40, 30, 3)
+
+	start := time.Date(2024, 5, 1, 15, 30, 0, 0, time.UTC)
+	png := converter.NameSource{Path: photo, From: ".png", To: ".webp", Dir: "out", Counter: 7, Time: start}
+	withOptions := func(src converter.NameSource, options converter.Options) converter.NameSource {
+		src.Options = options
+		return src
+	}
+	jpegSource := func(path string, options converter.Options) converter.NameSource {
+		return converter.NameSource{Path: path, From: ".jpeg", To: ".png", Options: options}
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		src      converter.NameSource
+		want     string
+		err      string
+	}{
+		{"fields", "{dir}/{stem}-{from}.{ext}", png, filepath.Join("out", "photo-png.webp"), ""},
+		{"counter", "{n}-{n:3}", png, "7-007", ""},
+		{"time", "{date}T{time}", png, "2024-05-01T153000", ""},
+		{"braces", "{{{stem}}}", png, "{photo}", ""},
+		{"option", "{stem}-q{quality}", withOptions(png, converter.Options{"quality": 80}), "photo-q80", ""},
+		{"stdin", "{stem}.{ext}", converter.NameSource{Path: "-", To: ".png"}, "stdin.png", ""},
+
+		{"size", "{width}x{height}", png, "40x30", ""},
+		{"resized", "{width}x{height}", withOptions(png, converter.Options{"width": 20}), "20x15", ""},
+		{"cropped and rotated", "{width}x{height}", withOptions(png, converter.Options{"crop": "0,0,10,20", "rotate": 90}), "20x10", ""},
+		{"exif orientation", "{width}x{height}", jpegSource(rotated, nil), "30x40", ""},
+		{"exif orientation resized", "{width}x{height}", jpegSource(rotated, converter.Options{"height": 20}), "15x20", ""},
+		{"exif orientation ignored", "{width}x{height}", jpegSource(rotated, converter.Options{"auto-orient": false}), "40x30", ""},
+		{"exif orientation without swap", "{width}x{height}", jpegSource(upsideDown, nil), "40x30", ""},
+
+		{"unknown", "{bogus}", png, "", "unknown placeholder {bogus}"},
+		{"counter width", "{n:x}", png, "", "invalid counter width"},
+		{"size of stdin", "{width}", converter.NameSource{Path: "-", From: ".png"}, "", "not available when reading from stdin"},
+		{"size of missing file", "{width}", converter.NameSource{Path: filepath.Join(dir, "missing.png"), From: ".png"}, "", "missing.png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := converter.ParseNameTemplate(tt.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := tmpl.Expand(tt.src)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("Expand = %v", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("Expand = %q, %v, want an error containing %q", got, err, tt.err)
+			case tt.err == "" && got != tt.want:
+				t.Errorf("Expand = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/pflag"
+)
+
+// WarningFunc receives non-fatal messages from converters, e.g. when data
+// is discarded because the target format cannot represent it.
+type WarningFunc func(msg string)
+
+// OptionsFromFlags collects the typed values of all flags in fs, keyed by flag name.
+func OptionsFromFlags(fs *pflag.FlagSet) Options {
+	options := make(Options)
+	fs.VisitAll(func(f *pflag.Flag) {
+		var value interface{}
+		var err error
+		switch f.Value.Type() {
+		case "bool":
+			value, err = fs.GetBool(f.Name)
+		case "int":
+			value, err = fs.GetInt(f.Name)
+		case "float32":
+			var v float32
+			v, err = fs.GetFloat32(f.Name)
+			value = float64(v)
+		case "float64":
+			value, err = fs.GetFloat64(f.Name)
+		case "stringSlice":
+			value, err = fs.GetStringSlice(f.Name)
+		default:
+			value = f.Value.String()
+		}
+		if err == nil {
+			options[f.Name] = value
+		}
+	})
+	return options
+}
+
+// Bool returns the boolean option name, or def if it is not set.
+func (o Options) Bool(name string, def bool) bool {
+	if v, ok := o[name].(bool); ok {
+		return v
+	}
+	return def
+}
+
+// Int returns the integer option name, or def if it is not set.
+func (o Options) Int(name string, def int) int {
+	if v, ok := o[name].(int); ok {
+		return v
+	}
+	return def
+}
+
+// Float64 returns the floating point option name, or def if it is not set.
+func (o Options) Float64(name string, def float64) float64 {
+	if v, ok := o[name].(float64); ok {
+		return v
+	}
+	return def
+}
+
+// String returns the string option name, or def if it is not set.
+func (o Options) String(name string, def string) string {
+	if v, ok := o[name].(string); ok {
+		return v
+	}
+	return def
+}
+
+// StringSlice returns the string list option name, or def if it is not set.
+func (o Options) StringSlice(name string, def []string) []string {
+	if v, ok := o[name].([]string); ok {
+		return v
+	}
+	return def
+}
+
+// WithWarningFunc returns a copy of o that reports warnings to f.
+func (o Options) WithWarningFunc(f WarningFunc) Options {
+	options := make(Options, len(o)+1)
+	for k, v := range o {
+		options[k] = v
+	}
+	options[warningKey] = f
+	return options
+}
+
+// Warn reports a warning through the WarningFunc set with WithWarningFunc,
+// or prints it to stderr if there is none.
+func (o Options) Warn(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if f, ok := o[warningKey].(WarningFunc); ok {
+		f(msg)
+		return
+	}
+	fmt.Fprintln(os.Stderr, "warning: "+msg)
+}
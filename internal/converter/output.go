@@ -0,0 +1,103 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ConflictPolicy decides what happens when an output file already exists.
+type ConflictPolicy int
+
+const (
+	// FailOnConflict refuses to replace an existing file.
+	FailOnConflict ConflictPolicy = iota
+	// Overwrite replaces the existing file.
+	Overwrite
+	// NoClobber leaves the existing file alone and skips the conversion.
+	NoClobber
+	// SuffixOnConflict writes to a free name such as "photo (1).webp".
+	SuffixOnConflict
+)
+
+var (
+	// ErrOutputExists is returned by Outputs.Resolve if the output file
+	// exists and the policy does not allow writing it.
+	ErrOutputExists = errors.New("output file already exists")
+	// ErrSameFile is returned by Outputs.Resolve if the output file is
+	// the input file. This is refused whatever the policy.
+	ErrSameFile = errors.New("output file is the input file")
+)
+
+// DefaultOutputPath returns inputPath with its extension replaced by the
+// destination format to.
+func DefaultOutputPath(inputPath, to string) string {
+	return strings.TrimSuffix(inputPath, filepath.Ext(inputPath)) + to
+}
+
+// Outputs resolves output paths according to a ConflictPolicy. Paths it has
+// handed out count as taken, so that the conversions of one run never
+// write the same file. It is safe for concurrent use.
+type Outputs struct {
+	Policy ConflictPolicy
+
+	mu      sync.Mutex
+	claimed map[string]bool
+}
+
+// Resolve returns the path the conversion of inputPath should write to in
+// place of outputPath, or an error wrapping ErrOutputExists or ErrSameFile.
+func (o *Outputs) Resolve(inputPath, outputPath string) (string, error) {
+	if in, err := os.Stat(inputPath); err == nil {
+		if out, err := os.Stat(outputPath); err == nil && os.SameFile(in, out) {
+			return "", fmt.Errorf("%s: %w", outputPath, ErrSameFile)
+		}
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.claimed == nil {
+		o.claimed = make(map[string]bool)
+	}
+
+	path := outputPath
+	if o.taken(path) {
+		switch o.Policy {
+		case Overwrite:
+			// Replacing a file written earlier in the same run would
+			// silently lose a result.
+			if o.claimed[o.key(path)] {
+				return "", fmt.Errorf("%s: %w (written by another input)", path, ErrOutputExists)
+			}
+		case SuffixOnConflict:
+			ext := filepath.Ext(outputPath)
+			stem := strings.TrimSuffix(outputPath, ext)
+			for i := 1; o.taken(path); i++ {
+				path = fmt.Sprintf("%s (%d)%s", stem, i, ext)
+			}
+		default:
+			return "", fmt.Errorf("%s: %w", path, ErrOutputExists)
+		}
+	}
+	o.claimed[o.key(path)] = true
+	return path, nil
+}
+
+// taken reports whether path exists or has been handed out before.
+func (o *Outputs) taken(path string) bool {
+	if o.claimed[o.key(path)] {
+		return true
+	}
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+func (o *Outputs) key(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
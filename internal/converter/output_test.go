@@ -0,0 +1,85 @@
+package converter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// resolveConcurrently resolves the same output path from n goroutines and
+// returns the paths handed out and the number of ErrOutputExists errors.
+func resolveConcurrently(t *testing.T, o *Outputs, dir string, n int) ([]string, int) {
+	t.Helper()
+	var (
+		mu     sync.Mutex
+		paths  []string
+		exists int
+		wg     sync.WaitGroup
+	)
+	out := filepath.Join(dir, "photo.webp")
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			path, err := o.Resolve(filepath.Join(dir, "photo.png"), out)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				paths = append(paths, path)
+			case errors.Is(err, ErrOutputExists):
+				exists++
+			default:
+				t.Errorf("Resolve: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	return paths, exists
+}
+
+func TestOutputsResolveConcurrentSuffix(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "photo.webp"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 32
+	paths, exists := resolveConcurrently(t, &Outputs{Policy: SuffixOnConflict}, dir, n)
+	if exists != 0 || len(paths) != n {
+		t.Fatalf("got %d paths and %d conflicts, want %d paths", len(paths), exists, n)
+	}
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		if seen[path] {
+			t.Errorf("%s handed out twice", path)
+		}
+		seen[path] = true
+		if path == filepath.Join(dir, "photo.webp") {
+			t.Errorf("existing file %s handed out", path)
+		}
+	}
+}
+
+func TestOutputsResolveConcurrentClaimsOnce(t *testing.T) {
+	for _, policy := range []ConflictPolicy{FailOnConflict, Overwrite, NoClobber} {
+		const n = 32
+		paths, exists := resolveConcurrently(t, &Outputs{Policy: policy}, t.TempDir(), n)
+		if len(paths) != 1 || exists != n-1 {
+			t.Errorf("policy %d: got %d paths and %d conflicts, want 1 path and %d conflicts", policy, len(paths), exists, n-1)
+		}
+	}
+}
+
+func TestOutputsResolveSameFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "photo.png")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&Outputs{Policy: Overwrite}).Resolve(path, path); !errors.Is(err, ErrSameFile) {
+		t.Errorf("Resolve(input, input) = %v, want ErrSameFile", err)
+	}
+}
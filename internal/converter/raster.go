@@ -0,0 +1,211 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/renja-g/convert/internal/icc"
+	"github.com/spf13/pflag"
+)
+
+// rasterConverter converts between two raster formats by decoding the input
+// into an image.Image, converting its colours to the output profile,
+// applying the transform options and encoding it again in the target
+// format, with the input's metadata if both formats can store it. If both
+// formats support animation and no single frame was requested, all frames
+// are converted.
+type rasterConverter struct {
+	decoder Decoder
+	encoder Encoder
+}
+
+func (c *rasterConverter) From() string { return c.decoder.Format() }
+func (c *rasterConverter) To() string   { return c.encoder.Format() }
+
+// lossyCost is the cost of a step that ends in a lossy encoder. A chain
+// then prefers any lossless intermediate format to a lossy one, while a
+// direct lossy conversion still beats a lossless detour.
+const lossyCost = 3
+
+// Cost implements Coster.
+func (c *rasterConverter) Cost() int {
+	if l, ok := c.encoder.(LossyEncoder); ok && l.Lossy() {
+		return lossyCost
+	}
+	return 1
+}
+
+func (c *rasterConverter) Convert(ctx context.Context, inputPath, outputPath string, options Options) error {
+	return ConvertFile(ctx, c, inputPath, outputPath, options)
+}
+
+func (c *rasterConverter) ConvertStream(ctx context.Context, r io.Reader, w io.Writer, options Options) error {
+	err := c.convert(ctx, contextReader{ctx, r}, contextWriter{ctx, w}, options)
+	if err != nil && ctx.Err() != nil {
+		// Decoders and encoders don't necessarily wrap the errors of the
+		// reader and writer, so report the cancellation itself.
+		return ctx.Err()
+	}
+	return err
+}
+
+func (c *rasterConverter) convert(ctx context.Context, r io.Reader, w io.Writer, options Options) error {
+	t, err := newTransform(options)
+	if err != nil {
+		return err
+	}
+	policy, err := newMetadataPolicy(options)
+	if err != nil {
+		return err
+	}
+	colors, err := newColorConversion(options)
+	if err != nil {
+		return err
+	}
+	limits := options.Limits()
+	var limited *limitReader
+	if limits.MaxInputBytes > 0 {
+		limited = &limitReader{r: r, max: limits.MaxInputBytes}
+		r = limited
+	}
+	decodeError := func(err error) error {
+		var limitErr *LimitError
+		switch {
+		case limited != nil && limited.exceeded:
+			// Decoders don't necessarily pass on the reader's error.
+			return limited.err()
+		case errors.As(err, &limitErr):
+			return err
+		}
+		return &DecodeError{Format: c.From(), Err: err}
+	}
+
+	r, err = checkHeader(c.decoder, r, limits)
+	if err != nil {
+		return decodeError(err)
+	}
+	var md *Metadata
+	if t.autoOrient || policy.copiesAny() || colors != nil {
+		md, r = readMetadata(c.decoder, r)
+		t.setOrientation(md)
+	}
+	ct := colors.transform(md, options)
+	md = policy.filter(md, t.orientation != orientNormal, options)
+	// Converted colours are tagged with their new profile, even if the
+	// ICC metadata is stripped: without it they would be taken for sRGB.
+	if ct != nil && (policy.keeps(keepICC) || !colors.target.Same(icc.SRGB)) {
+		if md == nil {
+			md = &Metadata{}
+		}
+		md.ICC = colors.target.Bytes()
+	}
+
+	ad, canDecode := c.decoder.(AnimationDecoder)
+	ae, canEncode := c.encoder.(AnimationEncoder)
+	if canDecode && canEncode && options.Int("frame", -1) < 0 {
+		anim, err := ad.DecodeAnimation(r, options)
+		if err != nil {
+			return decodeError(err)
+		}
+		if err := checkDecoded(anim.Width, anim.Height, len(anim.Frames), limits); err != nil {
+			return err
+		}
+		if err := checkTransformed(t, anim.Width, anim.Height, limits); err != nil {
+			return err
+		}
+		if ct != nil {
+			convertAnimationColors(anim, ct)
+		}
+		if anim, err = t.applyAnimation(anim); err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return c.encode(w, md, options, func(w io.Writer) error {
+			return ae.EncodeAnimation(w, anim, options)
+		})
+	}
+
+	img, err := c.decoder.Decode(r, options)
+	if err != nil {
+		return decodeError(err)
+	}
+	b := img.Bounds()
+	if err := checkDecoded(b.Dx(), b.Dy(), 1, limits); err != nil {
+		return err
+	}
+	if err := checkTransformed(t, b.Dx(), b.Dy(), limits); err != nil {
+		return err
+	}
+	if ct != nil {
+		img = convertColors(img, ct)
+	}
+	if img, err = t.apply(img); err != nil {
+		return err
+	}
+	// Encoding is the expensive part, so don't start it if the
+	// conversion has been cancelled in the meantime.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return c.encode(w, md, options, func(w io.Writer) error {
+		return c.encoder.Encode(w, img, options)
+	})
+}
+
+// encode writes the output with fn and embeds md in it, if the target
+// format can store metadata.
+func (c *rasterConverter) encode(w io.Writer, md *Metadata, options Options, fn func(io.Writer) error) error {
+	if md.empty() {
+		return fn(w)
+	}
+	me, ok := c.encoder.(MetadataEncoder)
+	if !ok {
+		options.Warn("%s does not support metadata; dropping %s", strings.ToUpper(strings.TrimPrefix(c.To(), ".")), md.names())
+		return fn(w)
+	}
+
+	var buf bytes.Buffer
+	if err := fn(&buf); err != nil {
+		return err
+	}
+	data, err := me.EmbedMetadata(buf.Bytes(), md, options)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (c *rasterConverter) GetFlags() *pflag.FlagSet {
+	name := strings.TrimPrefix(c.From(), ".") + "-to-" + strings.TrimPrefix(c.To(), ".")
+	flags := pflag.NewFlagSet(name, pflag.ExitOnError)
+	flags.AddFlagSet(c.decoder.GetFlags())
+	flags.AddFlagSet(c.encoder.GetFlags())
+	flags.AddFlagSet(transformFlags())
+	flags.AddFlagSet(metadataFlags())
+	flags.AddFlagSet(colorFlags())
+	return flags
+}
+
+// readMetadata reads the metadata of the input of d, if d is a
+// MetadataDecoder. The returned reader yields the complete input again.
+// Metadata that cannot be read is ignored; decoding reports broken files.
+func readMetadata(d Decoder, r io.Reader) (*Metadata, io.Reader) {
+	md, ok := d.(MetadataDecoder)
+	if !ok {
+		return nil, r
+	}
+	var metadata *Metadata
+	r, _ = peek(r, func(r io.Reader) error {
+		var err error
+		metadata, err = md.DecodeMetadata(r)
+		return err
+	})
+	return metadata, r
+}
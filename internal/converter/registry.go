@@ -1,9 +1,25 @@
 package converter
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+
+	"github.com/renja-g/convert/internal/alias"
+)
 
 var registry = make(map[string]map[string]Converter)
 
+var (
+	decoders = make(map[string]Decoder)
+	encoders = make(map[string]Encoder)
+)
+
+// Coster can be implemented by converters whose step in a conversion chain
+// should weigh more than a single hop (e.g. lossy encoders).
+type Coster interface {
+	Cost() int
+}
+
 // Register adds a converter to the registry.
 func Register(c Converter) {
 	from := c.From()
@@ -20,16 +36,186 @@ func Register(c Converter) {
 	registry[from][to] = c
 }
 
+// RegisterDecoder adds a decoder and registers a converter from its format
+// to every format an encoder is registered for.
+func RegisterDecoder(d Decoder) {
+	format := d.Format()
+	if _, ok := decoders[format]; ok {
+		panic(fmt.Sprintf("decoder for %s already registered", format))
+	}
+	decoders[format] = d
+
+	for _, e := range encoders {
+		if alias.Resolve(e.Format()) != alias.Resolve(format) {
+			Register(&rasterConverter{decoder: d, encoder: e})
+		}
+	}
+}
+
+// RegisterEncoder adds an encoder and registers a converter to its format
+// from every format a decoder is registered for.
+func RegisterEncoder(e Encoder) {
+	format := e.Format()
+	if _, ok := encoders[format]; ok {
+		panic(fmt.Sprintf("encoder for %s already registered", format))
+	}
+	encoders[format] = e
+
+	for _, d := range decoders {
+		if alias.Resolve(d.Format()) != alias.Resolve(format) {
+			Register(&rasterConverter{decoder: d, encoder: e})
+		}
+	}
+}
+
+// Formats returns every format a registered converter reads or writes,
+// sorted by name.
+func Formats() []string {
+	seen := make(map[string]bool)
+	for from, targets := range registry {
+		seen[from] = true
+		for to := range targets {
+			seen[to] = true
+		}
+	}
+	formats := make([]string, 0, len(seen))
+	for format := range seen {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// IsRaster reports whether format is read or written by a raster decoder
+// or encoder.
+func IsRaster(format string) bool {
+	_, isDecoder := decoders[format]
+	_, isEncoder := encoders[format]
+	return isDecoder || isEncoder
+}
+
 // GetConvertersFor returns all available converters for a given source extension.
+// Targets that can only be reached through intermediate formats are included
+// as chained converters.
 func GetConvertersFor(from string) map[string]Converter {
-	return registry[from]
+	paths := shortestPaths(from)
+	if len(paths) == 0 {
+		return nil
+	}
+
+	converters := make(map[string]Converter, len(paths))
+	for to, steps := range paths {
+		converters[to] = newConverter(steps)
+	}
+	return converters
+}
+
+// GetConvertersTo returns a converter to the given destination extension
+// for every source extension that can reach it.
+func GetConvertersTo(to string) map[string]Converter {
+	converters := make(map[string]Converter)
+	for from := range registry {
+		if steps, ok := FindPath(from, to); ok {
+			converters[from] = newConverter(steps)
+		}
+	}
+	return converters
 }
 
 // GetConverter returns a specific converter for a source and destination extension.
+// If no direct converter is registered, the cheapest chain of converters is returned.
 func GetConverter(from, to string) (Converter, bool) {
-	if converters, ok := registry[from]; ok {
-		converter, ok := converters[to]
-		return converter, ok
+	steps, ok := FindPath(from, to)
+	if !ok {
+		return nil, false
+	}
+	return newConverter(steps), true
+}
+
+// FindPath returns the cheapest sequence of registered converters leading
+// from one extension to another.
+func FindPath(from, to string) ([]Converter, bool) {
+	steps, ok := shortestPaths(from)[to]
+	return steps, ok
+}
+
+// Route returns the formats a converter passes through, starting with its
+// source and ending with its destination extension.
+func Route(c Converter) []string {
+	route := []string{c.From()}
+	if ch, ok := c.(*chain); ok {
+		for _, step := range ch.steps {
+			route = append(route, step.To())
+		}
+		return route
+	}
+	return append(route, c.To())
+}
+
+func newConverter(steps []Converter) Converter {
+	if len(steps) == 1 {
+		return steps[0]
+	}
+	return &chain{steps: steps}
+}
+
+func cost(c Converter) int {
+	if cc, ok := c.(Coster); ok {
+		return cc.Cost()
+	}
+	return 1
+}
+
+// shortestPaths runs Dijkstra over the registry, treating every registered
+// converter as an edge, and returns the cheapest chain to each reachable format.
+func shortestPaths(from string) map[string][]Converter {
+	dist := map[string]int{from: 0}
+	prev := make(map[string]Converter)
+	visited := make(map[string]bool)
+
+	for {
+		// Pick the closest unvisited format; ties are broken by name so
+		// that the chosen path is stable between runs.
+		current, found := "", false
+		for ext, d := range dist {
+			if visited[ext] {
+				continue
+			}
+			if !found || d < dist[current] || (d == dist[current] && ext < current) {
+				current, found = ext, true
+			}
+		}
+		if !found {
+			break
+		}
+		visited[current] = true
+
+		targets := make([]string, 0, len(registry[current]))
+		for to := range registry[current] {
+			targets = append(targets, to)
+		}
+		sort.Strings(targets)
+
+		for _, to := range targets {
+			c := registry[current][to]
+			d := dist[current] + cost(c)
+			if old, ok := dist[to]; !ok || d < old {
+				dist[to] = d
+				prev[to] = c
+			}
+		}
+	}
+
+	paths := make(map[string][]Converter)
+	for to := range prev {
+		if to == from {
+			continue
+		}
+		var steps []Converter
+		for ext := to; ext != from; ext = prev[ext].From() {
+			steps = append([]Converter{prev[ext]}, steps...)
+		}
+		paths[to] = steps
 	}
-	return nil, false
+	return paths
 }
@@ -0,0 +1,64 @@
+package converter_test
+
+import (
+	"context"
+	"image"
+	"io"
+	"testing"
+
+	"github.com/renja-g/convert/internal/converter"
+	"github.com/spf13/pflag"
+)
+
+// sourceDecoder reads a format that only raster converters start from.
+type sourceDecoder struct{}
+
+func (d *sourceDecoder) Format() string { return ".costsource" }
+func (d *sourceDecoder) Decode(r io.Reader, options converter.Options) (image.Image, error) {
+	return nil, io.ErrUnexpectedEOF
+}
+func (d *sourceDecoder) GetFlags() *pflag.FlagSet {
+	return pflag.NewFlagSet("costsource", pflag.ExitOnError)
+}
+
+// documentConverter writes a format that only it reaches, from from.
+type documentConverter struct{ from string }
+
+func (c *documentConverter) From() string { return c.from }
+func (c *documentConverter) To() string   { return ".costdocument" }
+func (c *documentConverter) Convert(ctx context.Context, inputPath, outputPath string, options converter.Options) error {
+	return nil
+}
+func (c *documentConverter) ConvertStream(ctx context.Context, r io.Reader, w io.Writer, options converter.Options) error {
+	return nil
+}
+func (c *documentConverter) GetFlags() *pflag.FlagSet {
+	return pflag.NewFlagSet("costdocument", pflag.ExitOnError)
+}
+
+// The registry is global, so the test formats are registered once, not
+// by every run of the test.
+func init() {
+	converter.RegisterDecoder(&sourceDecoder{})
+	converter.Register(&documentConverter{from: ".jpeg"})
+	converter.Register(&documentConverter{from: ".png"})
+}
+
+// TestFindPathAvoidsLossyIntermediates checks that a chain passes through
+// PNG rather than JPEG, which would otherwise win the tie by name.
+func TestFindPathAvoidsLossyIntermediates(t *testing.T) {
+	steps, ok := converter.FindPath(".costsource", ".costdocument")
+	if !ok {
+		t.Fatal("no path found")
+	}
+	if len(steps) != 2 || steps[0].To() != ".png" {
+		t.Errorf("route %v, want .costsource, .png and .costdocument", converter.Route(converter.NewChain(steps...)))
+	}
+
+	// A direct lossy conversion beats a lossless detour.
+	for _, to := range []string{".jpeg", ".gif", ".webp"} {
+		if steps, ok := converter.FindPath(".png", to); !ok || len(steps) != 1 {
+			t.Errorf("path from .png to %s has %d steps, want 1", to, len(steps))
+		}
+	}
+}
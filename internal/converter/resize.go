@@ -0,0 +1,291 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// filter is a resampling filter: a kernel evaluated at distances of up to
+// support source pixels, scaled up when downsampling.
+type filter struct {
+	support float64
+	kernel  func(x float64) float64
+}
+
+// filters are the resampling filters selectable with --filter. Nearest
+// neighbour has no kernel and picks the closest source pixel.
+var filters = map[string]filter{
+	"nearest":     {},
+	"bilinear":    {support: 1, kernel: triangle},
+	"catmull-rom": {support: 2, kernel: catmullRom},
+	"lanczos":     {support: 3, kernel: lanczos3},
+}
+
+func triangle(x float64) float64 {
+	return 1 - x
+}
+
+// catmullRom is the cubic filter with B = 0 and C = 0.5.
+func catmullRom(x float64) float64 {
+	if x < 1 {
+		return (1.5*x-2.5)*x*x + 1
+	}
+	return ((-0.5*x+2.5)*x-4)*x + 2
+}
+
+func lanczos3(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	px := math.Pi * x
+	return 3 * math.Sin(px) * math.Sin(px/3) / (px * px)
+}
+
+// contribution lists the weights of the source pixels, starting at start,
+// that make up one destination pixel.
+type contribution struct {
+	start   int
+	weights []float32
+}
+
+// contributions computes the weights for scaling srcLen pixels to dstLen.
+func (f filter) contributions(dstLen, srcLen int) []contribution {
+	scale := float64(srcLen) / float64(dstLen)
+	c := make([]contribution, dstLen)
+	if f.kernel == nil {
+		for i := range c {
+			j := min(int((float64(i)+0.5)*scale), srcLen-1)
+			c[i] = contribution{start: j, weights: []float32{1}}
+		}
+		return c
+	}
+
+	// When downsampling the kernel is stretched over the source pixels
+	// that fall into one destination pixel, so that none are skipped.
+	stretch := max(scale, 1)
+	support := f.support * stretch
+	for i := range c {
+		center := (float64(i) + 0.5) * scale
+		start := max(int(math.Floor(center-support)), 0)
+		end := min(int(math.Ceil(center+support)), srcLen)
+		weights := make([]float32, end-start)
+		var sum float64
+		for j := range weights {
+			x := math.Abs(float64(start+j)+0.5-center) / stretch
+			if x < f.support {
+				w := f.kernel(x)
+				weights[j] = float32(w)
+				sum += w
+			}
+		}
+		if sum != 0 {
+			for j := range weights {
+				weights[j] /= float32(sum)
+			}
+		}
+		c[i] = contribution{start: start, weights: weights}
+	}
+	return c
+}
+
+// resample scales img to width × height. It works on premultiplied alpha,
+// so that the colour of transparent pixels doesn't bleed into their
+// neighbours, in two passes: first along the rows, then along the columns.
+// The result has the pixel format of toWorking(img).
+func resample(img image.Image, width, height int, f filter) image.Image {
+	src := toWorking(img)
+	b := src.Bounds()
+	cols := f.contributions(width, b.Dx())
+	rows := f.contributions(height, b.Dy())
+
+	line := make([]float32, 4*b.Dx())
+	tmp := make([]float32, 4*width*b.Dy())
+	for y := 0; y < b.Dy(); y++ {
+		readRow(src, y, line)
+		out := tmp[4*width*y:]
+		for x, c := range cols {
+			var sum [4]float32
+			for i, w := range c.weights {
+				p := line[4*(c.start+i):]
+				sum[0] += w * p[0]
+				sum[1] += w * p[1]
+				sum[2] += w * p[2]
+				sum[3] += w * p[3]
+			}
+			copy(out[4*x:], sum[:])
+		}
+	}
+
+	dst := newWorking(src, image.Rect(0, 0, width, height))
+	line = make([]float32, 4*width)
+	for y, c := range rows {
+		for x := 0; x < width; x++ {
+			var sum [4]float32
+			for i, w := range c.weights {
+				p := tmp[4*((c.start+i)*width+x):]
+				sum[0] += w * p[0]
+				sum[1] += w * p[1]
+				sum[2] += w * p[2]
+				sum[3] += w * p[3]
+			}
+			copy(line[4*x:], sum[:])
+		}
+		writeRow(dst, y, line)
+	}
+	return dst
+}
+
+// readRow stores row y of m, an image returned by toWorking, in line as
+// premultiplied values between 0 and 1.
+func readRow(m image.Image, y int, line []float32) {
+	switch m := m.(type) {
+	case *image.RGBA:
+		row := m.Pix[y*m.Stride:]
+		for i := range line {
+			line[i] = float32(row[i]) / 0xff
+		}
+	case *image.NRGBA:
+		row := m.Pix[y*m.Stride:]
+		for i := 0; i < len(line); i += 4 {
+			a := float32(row[i+3]) / 0xff
+			line[i] = float32(row[i]) / 0xff * a
+			line[i+1] = float32(row[i+1]) / 0xff * a
+			line[i+2] = float32(row[i+2]) / 0xff * a
+			line[i+3] = a
+		}
+	case *image.NRGBA64:
+		row := m.Pix[y*m.Stride:]
+		for i := 0; i < len(line); i += 4 {
+			p := row[2*i:]
+			a := float32(uint16(p[6])<<8|uint16(p[7])) / 0xffff
+			line[i] = float32(uint16(p[0])<<8|uint16(p[1])) / 0xffff * a
+			line[i+1] = float32(uint16(p[2])<<8|uint16(p[3])) / 0xffff * a
+			line[i+2] = float32(uint16(p[4])<<8|uint16(p[5])) / 0xffff * a
+			line[i+3] = a
+		}
+	}
+}
+
+// writeRow stores the premultiplied values of line, which filters with
+// negative lobes may have pushed out of range, in row y of m, an image
+// returned by newWorking.
+func writeRow(m image.Image, y int, line []float32) {
+	switch m := m.(type) {
+	case *image.RGBA:
+		row := m.Pix[y*m.Stride:]
+		for i := 0; i < len(line); i += 4 {
+			// Premultiplied colour must not exceed alpha.
+			a := clamp8(line[i+3]*0xff, 0xff)
+			row[i] = clamp8(line[i]*0xff, a)
+			row[i+1] = clamp8(line[i+1]*0xff, a)
+			row[i+2] = clamp8(line[i+2]*0xff, a)
+			row[i+3] = a
+		}
+	case *image.NRGBA:
+		row := m.Pix[y*m.Stride:]
+		for i := 0; i < len(line); i += 4 {
+			a := line[i+3]
+			row[i+3] = clamp8(a*0xff, 0xff)
+			if row[i+3] == 0 {
+				row[i], row[i+1], row[i+2] = 0, 0, 0
+				continue
+			}
+			row[i] = clamp8(line[i]/a*0xff, 0xff)
+			row[i+1] = clamp8(line[i+1]/a*0xff, 0xff)
+			row[i+2] = clamp8(line[i+2]/a*0xff, 0xff)
+		}
+	case *image.NRGBA64:
+		row := m.Pix[y*m.Stride:]
+		for i := 0; i < len(line); i += 4 {
+			a := line[i+3]
+			v := [4]uint16{3: clamp16(a * 0xffff)}
+			if v[3] != 0 {
+				v[0] = clamp16(line[i] / a * 0xffff)
+				v[1] = clamp16(line[i+1] / a * 0xffff)
+				v[2] = clamp16(line[i+2] / a * 0xffff)
+			}
+			p := row[2*i:]
+			for j, c := range v {
+				p[2*j], p[2*j+1] = uint8(c>>8), uint8(c)
+			}
+		}
+	}
+}
+
+// clamp8 rounds v to an integer between 0 and hi.
+func clamp8(v float32, hi uint8) uint8 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= float32(hi):
+		return hi
+	}
+	return uint8(v + 0.5)
+}
+
+// clamp16 rounds v to an integer between 0 and 0xffff.
+func clamp16(v float32) uint16 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 0xffff:
+		return 0xffff
+	}
+	return uint16(v + 0.5)
+}
+
+// toWorking returns img in the pixel format that the transforms work on,
+// converting it if necessary. Images with more than 8 bits per channel
+// become *image.NRGBA64 and other images that are not premultiplied
+// *image.NRGBA, so that no precision is lost; the rest become *image.RGBA.
+func toWorking(img image.Image) image.Image {
+	b := img.Bounds()
+	var m draw.Image
+	switch {
+	case sixteenBit(img):
+		if n, ok := img.(*image.NRGBA64); ok {
+			return n
+		}
+		m = image.NewNRGBA64(b)
+	case img.ColorModel() == color.NRGBAModel:
+		if n, ok := img.(*image.NRGBA); ok {
+			return n
+		}
+		m = image.NewNRGBA(b)
+	default:
+		if n, ok := img.(*image.RGBA); ok {
+			return n
+		}
+		m = image.NewRGBA(b)
+	}
+	draw.Draw(m, b, img, b.Min, draw.Src)
+	return m
+}
+
+// newWorking returns a new image with bounds r and the pixel format of m,
+// an image returned by toWorking.
+func newWorking(m image.Image, r image.Rectangle) image.Image {
+	switch m.(type) {
+	case *image.NRGBA64:
+		return image.NewNRGBA64(r)
+	case *image.NRGBA:
+		return image.NewNRGBA(r)
+	}
+	return image.NewRGBA(r)
+}
+
+// pixels returns the pixel buffer of m, an image returned by toWorking or
+// newWorking, its stride and the number of bytes per pixel.
+func pixels(m image.Image) (pix []uint8, stride, size int) {
+	switch m := m.(type) {
+	case *image.NRGBA64:
+		return m.Pix, m.Stride, 8
+	case *image.NRGBA:
+		return m.Pix, m.Stride, 4
+	case *image.RGBA:
+		return m.Pix, m.Stride, 4
+	}
+	panic("converter: not a working image")
+}
@@ -0,0 +1,112 @@
+package converter_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"sync"
+	"testing"
+
+	"github.com/renja-g/convert/internal/converter"
+	_ "github.com/renja-g/convert/internal/converter/image/gif"
+	_ "github.com/renja-g/convert/internal/converter/image/jpeg"
+	_ "github.com/renja-g/convert/internal/converter/image/png"
+)
+
+// testImage returns a w × h gradient whose colours depend on seed, so that
+// concurrent conversions of different images can be told apart.
+func testImage(w, h, seed int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{uint8(x * 255 / w), uint8(y * 255 / h), uint8(seed * 37), 255})
+		}
+	}
+	return img
+}
+
+func encodePNG(t testing.TB, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// TestSharedConverter runs many conversions through one registered
+// converter at once. Run it with -race.
+func TestSharedConverter(t *testing.T) {
+	tests := []struct {
+		from, to string
+		input    func(t testing.TB, seed int) []byte
+		decode   func(data []byte) (image.Image, error)
+		options  converter.Options
+	}{
+		{
+			from: ".png", to: ".jpeg",
+			input: func(t testing.TB, seed int) []byte { return encodePNG(t, testImage(40+seed, 30, seed)) },
+			decode: func(data []byte) (image.Image, error) {
+				return jpeg.Decode(bytes.NewReader(data))
+			},
+			options: converter.Options{"quality": 80, "width": 20},
+		},
+		{
+			from: ".gif", to: ".png",
+			input: func(t testing.TB, seed int) []byte {
+				var buf bytes.Buffer
+				if err := gif.Encode(&buf, testImage(40+seed, 30, seed), nil); err != nil {
+					t.Fatal(err)
+				}
+				return buf.Bytes()
+			},
+			decode: func(data []byte) (image.Image, error) {
+				return png.Decode(bytes.NewReader(data))
+			},
+			options: converter.Options{"width": 20},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+"-to-"+tt.to, func(t *testing.T) {
+			c, ok := converter.GetConverter(tt.from, tt.to)
+			if !ok {
+				t.Fatalf("no converter from %s to %s", tt.from, tt.to)
+			}
+
+			const n = 16
+			inputs := make([][]byte, n)
+			for i := range inputs {
+				inputs[i] = tt.input(t, i)
+			}
+			var wg sync.WaitGroup
+			for i := 0; i < n; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					var out bytes.Buffer
+					if err := c.ConvertStream(context.Background(), bytes.NewReader(inputs[i]), &out, tt.options); err != nil {
+						t.Errorf("conversion %d: %v", i, err)
+						return
+					}
+					img, err := tt.decode(out.Bytes())
+					if err != nil {
+						t.Errorf("conversion %d: decoding the output: %v", i, err)
+						return
+					}
+					// Resizing (40 + i) × 30 to a width of 20 keeps the
+					// aspect ratio of the input.
+					want := image.Pt(20, (30*20+(40+i)/2)/(40+i))
+					if got := img.Bounds().Size(); got != want {
+						t.Errorf("conversion %d: output is %v, want %v", i, got, want)
+					}
+				}()
+			}
+			wg.Wait()
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/renja-g/convert/internal/alias"
+)
+
+// ConvertStream converts data read from r into w. The from and to format
+// hints are file extensions, with or without the leading dot (e.g. "png").
+func ConvertStream(ctx context.Context, r io.Reader, w io.Writer, from, to string, options Options) error {
+	from, to = normalizeFormat(from), normalizeFormat(to)
+
+	c, ok := GetConverter(from, to)
+	if !ok {
+		return fmt.Errorf("%w from %s to %s", ErrNoConverter, from, to)
+	}
+	return c.ConvertStream(ctx, r, w, options)
+}
+
+// ConvertFile implements the path-based Converter.Convert on top of
+// ConvertStream. If outputPath is empty it is derived from inputPath by
+// replacing the extension with the converter's destination format. The
+// output is written atomically, so if the conversion fails or ctx is
+// cancelled no partial file is left behind.
+func ConvertFile(ctx context.Context, c Converter, inputPath, outputPath string, options Options) error {
+	inputFile, err := os.Open(inputPath)
+	if err != nil {
+		return err
+	}
+	defer inputFile.Close()
+
+	if outputPath == "" {
+		outputPath = DefaultOutputPath(inputPath, c.To())
+	}
+
+	outputFile, err := CreateAtomic(outputPath)
+	if err != nil {
+		return err
+	}
+	defer outputFile.Abort()
+
+	if err := c.ConvertStream(ctx, inputFile, outputFile, options); err != nil {
+		return err
+	}
+	return outputFile.Commit()
+}
+
+func normalizeFormat(format string) string {
+	format = strings.ToLower(format)
+	if !strings.HasPrefix(format, ".") {
+		format = "." + format
+	}
+	return alias.Resolve(format)
+}
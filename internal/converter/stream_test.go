@@ -0,0 +1,177 @@
+package converter_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/renja-g/convert/internal/converter"
+	_ "github.com/renja-g/convert/internal/converter/image/webp"
+)
+
+func getConverter(t *testing.T, from, to string) converter.Converter {
+	t.Helper()
+	c, ok := converter.GetConverter(from, to)
+	if !ok {
+		t.Fatalf("no converter from %s to %s", from, to)
+	}
+	return c
+}
+
+// meanColor returns the average colour of img, to compare the outputs of
+// lossy conversions with their inputs.
+func meanColor(img image.Image) [3]float64 {
+	var sum [3]float64
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			sum[0] += float64(r >> 8)
+			sum[1] += float64(g >> 8)
+			sum[2] += float64(bl >> 8)
+		}
+	}
+	n := float64(b.Dx() * b.Dy())
+	return [3]float64{sum[0] / n, sum[1] / n, sum[2] / n}
+}
+
+func checkImage(t *testing.T, got, want image.Image, tolerance float64) {
+	t.Helper()
+	if got.Bounds().Size() != want.Bounds().Size() {
+		t.Fatalf("output is %v, want %v", got.Bounds().Size(), want.Bounds().Size())
+	}
+	g, w := meanColor(got), meanColor(want)
+	for i := range g {
+		if d := g[i] - w[i]; d < -tolerance || d > tolerance {
+			t.Errorf("mean colour %v, want %v", g, w)
+			return
+		}
+	}
+}
+
+func TestConvertStreamPNGToJPEG(t *testing.T) {
+	img := testImage(40, 30, 1)
+	var out bytes.Buffer
+	err := converter.ConvertStream(context.Background(), bytes.NewReader(encodePNG(t, img)), &out, "png", "jpg", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := jpeg.Decode(&out)
+	if err != nil {
+		t.Fatalf("decoding the output: %v", err)
+	}
+	checkImage(t, got, img, 2)
+}
+
+func TestConvertStreamChain(t *testing.T) {
+	img := testImage(40, 30, 2)
+	var in bytes.Buffer
+	if err := gif.Encode(&in, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	c := converter.NewChain(getConverter(t, ".gif", ".png"), getConverter(t, ".png", ".webp"))
+	if got := converter.Route(c); len(got) != 3 || got[1] != ".png" {
+		t.Fatalf("route %v, want .gif, .png and .webp", got)
+	}
+
+	var webp bytes.Buffer
+	if err := c.ConvertStream(context.Background(), &in, &webp, converter.Options{"lossless": true}); err != nil {
+		t.Fatal(err)
+	}
+	// Read the WebP back through a PNG.
+	var out bytes.Buffer
+	if err := converter.ConvertStream(context.Background(), &webp, &out, "webp", "png", nil); err != nil {
+		t.Fatalf("converting the output back: %v", err)
+	}
+	got, err := png.Decode(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The GIF palette costs some precision.
+	checkImage(t, got, img, 4)
+}
+
+// cancellingReader cancels a context once it has read n bytes.
+type cancellingReader struct {
+	r      io.Reader
+	n      int
+	cancel context.CancelFunc
+}
+
+func (r *cancellingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p[:min(len(p), max(r.n, 1))])
+	if r.n -= n; r.n <= 0 {
+		r.cancel()
+	}
+	return n, err
+}
+
+func TestConvertStreamCancelled(t *testing.T) {
+	input := encodePNG(t, testImage(200, 200, 3))
+	converters := map[string]converter.Converter{
+		"direct": getConverter(t, ".png", ".jpeg"),
+		"chain":  converter.NewChain(getConverter(t, ".png", ".gif"), getConverter(t, ".gif", ".jpeg")),
+	}
+	for name, c := range converters {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			r := &cancellingReader{r: bytes.NewReader(input), n: len(input) / 2, cancel: cancel}
+			err := c.ConvertStream(ctx, r, io.Discard, nil)
+			if err != ctx.Err() || !errors.Is(err, context.Canceled) {
+				t.Errorf("ConvertStream = %v, want %v", err, ctx.Err())
+			}
+		})
+	}
+}
+
+func TestConvertLeavesNoPartialFile(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "broken.png")
+	data := encodePNG(t, testImage(40, 30, 4))
+	// Cut the image data short.
+	if err := os.WriteFile(input, data[:len(data)/2], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	existing := filepath.Join(dir, "existing.jpeg")
+	if err := os.WriteFile(existing, []byte("keep me"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := getConverter(t, ".png", ".jpeg")
+	for _, output := range []string{filepath.Join(dir, "new.jpeg"), existing} {
+		if err := c.Convert(context.Background(), input, output, nil); err == nil {
+			t.Fatalf("converting a truncated file to %s succeeded", output)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if len(names) != 2 || names[0] != "broken.png" || names[1] != "existing.jpeg" {
+		t.Errorf("directory holds %v, want only broken.png and existing.jpeg", names)
+	}
+	if data, _ := os.ReadFile(existing); string(data) != "keep me" {
+		t.Errorf("existing output was changed to %q", data)
+	}
+}
+
+func TestConvertStreamUnknownFormat(t *testing.T) {
+	err := converter.ConvertStream(context.Background(), bytes.NewReader(nil), io.Discard, "png", "bmp", nil)
+	if !errors.Is(err, converter.ErrNoConverter) {
+		t.Errorf("ConvertStream = %v, want ErrNoConverter", err)
+	}
+}
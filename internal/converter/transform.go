@@ -0,0 +1,371 @@
+package converter
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/renja-g/convert/internal/exif"
+	"github.com/spf13/pflag"
+)
+
+// transformOptions are the options read by newTransform.
+var transformOptions = []string{"auto-orient", "crop", "gravity", "rotate", "flip", "resize", "width", "height", "fit", "filter"}
+
+// transformFlags returns the flags of the operations that raster
+// converters apply between decoding and encoding.
+func transformFlags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("transform", pflag.ExitOnError)
+	flags.Bool("auto-orient", true, "Rotate and flip the image as its EXIF orientation says to display it")
+	flags.String("crop", "", "Crop to x,y,w,h, or to WxH placed by --gravity")
+	flags.String("gravity", "center", "Where a WxH --crop is taken from: center, north, south, east, west, northeast, northwest, southeast or southwest")
+	flags.Int("rotate", 0, "Rotate clockwise by 90, 180 or 270 degrees")
+	flags.String("flip", "", "Flip horizontally (h) or vertically (v)")
+	flags.String("resize", "", "Resize to WxH, e.g. 800x600; omit one side (800x or x600) to keep the aspect ratio")
+	flags.Int("width", 0, "Resize to this width, keeping the aspect ratio unless --height is given too")
+	flags.Int("height", 0, "Resize to this height, keeping the aspect ratio unless --width is given too")
+	flags.String("fit", "contain", "How to resize to both a width and a height: contain (fit inside), cover (fill and crop), fill (stretch) or inside (like contain, but never enlarge)")
+	flags.String("filter", "lanczos", "Resampling filter: nearest, bilinear, catmull-rom or lanczos")
+	return flags
+}
+
+// transform is the sequence of operations applied to an image between
+// decoding and encoding, in this order: EXIF orientation, crop, rotation,
+// flip and resize. The crop rectangle thus refers to the image as it is
+// displayed.
+type transform struct {
+	autoOrient bool
+	// orientation is the EXIF orientation of the input, set with
+	// setOrientation once the metadata has been read.
+	orientation int
+	crop        *crop
+	// rotate and flip are the EXIF orientations that perform --rotate and
+	// --flip.
+	rotate, flip int
+	resize       *resize
+}
+
+// newTransform reads the transform options.
+func newTransform(options Options) (*transform, error) {
+	t := &transform{
+		autoOrient:  options.Bool("auto-orient", true),
+		orientation: orientNormal,
+		rotate:      orientNormal,
+		flip:        orientNormal,
+	}
+
+	switch degrees := options.Int("rotate", 0); degrees {
+	case 0:
+	case 90:
+		t.rotate = orientRotate90
+	case 180:
+		t.rotate = orientRotate180
+	case 270:
+		t.rotate = orientRotate270
+	default:
+		return nil, fmt.Errorf("invalid --rotate %d, use 90, 180 or 270", degrees)
+	}
+	switch flip := options.String("flip", ""); flip {
+	case "":
+	case "h":
+		t.flip = orientFlipH
+	case "v":
+		t.flip = orientFlipV
+	default:
+		return nil, fmt.Errorf("invalid --flip %q, use h or v", flip)
+	}
+
+	var err error
+	if t.crop, err = newCrop(options); err != nil {
+		return nil, err
+	}
+	if t.resize, err = newResize(options); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// setOrientation sets the EXIF orientation from the input's metadata, if
+// auto-orientation is enabled.
+func (t *transform) setOrientation(md *Metadata) {
+	if !t.autoOrient || md == nil || md.EXIF == nil {
+		return
+	}
+	if x, err := exif.Parse(md.EXIF); err == nil {
+		t.orientation = x.Orientation()
+	}
+}
+
+// identity reports whether the transform leaves images unchanged.
+func (t *transform) identity() bool {
+	return t.orientation == orientNormal && t.crop == nil && t.rotate == orientNormal &&
+		t.flip == orientNormal && t.resize == nil
+}
+
+// size returns the size of an image of the given size after the transform.
+func (t *transform) size(width, height int) (int, int, error) {
+	if swapsAxes(t.orientation) {
+		width, height = height, width
+	}
+	if t.crop != nil {
+		r, err := t.crop.rect(width, height)
+		if err != nil {
+			return 0, 0, err
+		}
+		width, height = r.Dx(), r.Dy()
+	}
+	if swapsAxes(t.rotate) {
+		width, height = height, width
+	}
+	if t.resize != nil {
+		_, crop := t.resize.plan(width, height)
+		width, height = crop.Dx(), crop.Dy()
+	}
+	return width, height, nil
+}
+
+// apply transforms img.
+func (t *transform) apply(img image.Image) (image.Image, error) {
+	img = orient(img, t.orientation)
+	if t.crop != nil {
+		b := img.Bounds()
+		r, err := t.crop.rect(b.Dx(), b.Dy())
+		if err != nil {
+			return nil, err
+		}
+		if r.Size() != b.Size() {
+			img = cropImage(img, r)
+		}
+	}
+	img = orient(img, t.rotate)
+	img = orient(img, t.flip)
+	if t.resize != nil {
+		img = t.resize.apply(img)
+	}
+	return img, nil
+}
+
+// applyAnimation transforms every frame of a. The frames are rendered onto
+// the full canvas first: partial frames transformed on their own would not
+// line up with each other.
+func (t *transform) applyAnimation(a *Animation) (*Animation, error) {
+	if len(a.Frames) == 0 || t.identity() {
+		return a, nil
+	}
+	out := &Animation{LoopCount: a.LoopCount, Frames: make([]Frame, len(a.Frames))}
+	for i, canvas := range a.Render() {
+		img, err := t.apply(canvas)
+		if err != nil {
+			return nil, err
+		}
+		out.Frames[i] = Frame{Image: img, Delay: a.Frames[i].Delay}
+	}
+	b := out.Frames[0].Image.Bounds()
+	out.Width, out.Height = b.Dx(), b.Dy()
+	return out, nil
+}
+
+// crop holds the --crop and --gravity options.
+type crop struct {
+	// area is set for x,y,w,h crops, size for WxH crops placed by gravity.
+	area    image.Rectangle
+	size    image.Point
+	gravity string
+}
+
+func newCrop(options Options) (*crop, error) {
+	s := options.String("crop", "")
+	gravity := options.String("gravity", "center")
+	switch gravity {
+	case "center", "north", "south", "east", "west", "northeast", "northwest", "southeast", "southwest":
+	default:
+		return nil, fmt.Errorf("unknown --gravity %q, use center, north, south, east, west, northeast, northwest, southeast or southwest", gravity)
+	}
+	if s == "" {
+		return nil, nil
+	}
+
+	invalid := fmt.Errorf("invalid --crop %q, expected x,y,w,h or WxH", s)
+	if w, h, ok := strings.Cut(strings.ToLower(s), "x"); ok {
+		width, err1 := strconv.Atoi(w)
+		height, err2 := strconv.Atoi(h)
+		if err1 != nil || err2 != nil || width < 1 || height < 1 {
+			return nil, invalid
+		}
+		return &crop{size: image.Pt(width, height), gravity: gravity}, nil
+	}
+
+	fields := strings.Split(s, ",")
+	if len(fields) != 4 {
+		return nil, invalid
+	}
+	var v [4]int
+	for i, f := range fields {
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil || n < 0 {
+			return nil, invalid
+		}
+		v[i] = n
+	}
+	if v[2] == 0 || v[3] == 0 {
+		return nil, invalid
+	}
+	return &crop{area: image.Rect(v[0], v[1], v[0]+v[2], v[1]+v[3])}, nil
+}
+
+// rect returns the part of a width × height image that is kept. A crop
+// reaching beyond the image is limited to it.
+func (c *crop) rect(width, height int) (image.Rectangle, error) {
+	bounds := image.Rect(0, 0, width, height)
+	if c.area.Empty() {
+		w, h := min(c.size.X, width), min(c.size.Y, height)
+		x, y := (width-w)/2, (height-h)/2
+		if strings.HasSuffix(c.gravity, "west") {
+			x = 0
+		} else if strings.HasSuffix(c.gravity, "east") {
+			x = width - w
+		}
+		if strings.HasPrefix(c.gravity, "north") {
+			y = 0
+		} else if strings.HasPrefix(c.gravity, "south") {
+			y = height - h
+		}
+		return image.Rect(x, y, x+w, y+h), nil
+	}
+	r := c.area.Intersect(bounds)
+	if r.Empty() {
+		return image.Rectangle{}, fmt.Errorf("--crop %d,%d,%d,%d lies outside the %dx%d image",
+			c.area.Min.X, c.area.Min.Y, c.area.Dx(), c.area.Dy(), width, height)
+	}
+	return r, nil
+}
+
+// Fit modes of --fit.
+const (
+	fitContain = "contain"
+	fitCover   = "cover"
+	fitFill    = "fill"
+	fitInside  = "inside"
+)
+
+// resize holds the --resize, --width, --height, --fit and --filter options.
+type resize struct {
+	// width and height are the requested size; 0 derives the side from the
+	// other one.
+	width, height int
+	fit           string
+	filter        filter
+}
+
+// newResize reads the resize options. It returns nil if no size was given.
+func newResize(options Options) (*resize, error) {
+	r := &resize{
+		width:  options.Int("width", 0),
+		height: options.Int("height", 0),
+		fit:    options.String("fit", fitContain),
+	}
+	if r.width < 0 || r.height < 0 {
+		return nil, fmt.Errorf("--width and --height must not be negative")
+	}
+	if s := options.String("resize", ""); s != "" {
+		if r.width != 0 || r.height != 0 {
+			return nil, fmt.Errorf("--resize cannot be combined with --width or --height")
+		}
+		var err error
+		if r.width, r.height, err = parseSize(s); err != nil {
+			return nil, err
+		}
+	}
+
+	switch r.fit {
+	case fitContain, fitCover, fitFill, fitInside:
+	default:
+		return nil, fmt.Errorf("unknown --fit %q, use contain, cover, fill or inside", r.fit)
+	}
+	name := options.String("filter", "lanczos")
+	f, ok := filters[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown --filter %q, use nearest, bilinear, catmull-rom or lanczos", name)
+	}
+	r.filter = f
+
+	if r.width == 0 && r.height == 0 {
+		return nil, nil
+	}
+	return r, nil
+}
+
+// parseSize parses a --resize value: WxH, Wx or xH.
+func parseSize(s string) (int, int, error) {
+	w, h, ok := strings.Cut(strings.ToLower(s), "x")
+	if !ok || w == "" && h == "" {
+		return 0, 0, fmt.Errorf("invalid --resize %q, expected WxH, Wx or xH", s)
+	}
+	side := func(v string) (int, error) {
+		if v == "" {
+			return 0, nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("invalid --resize %q, expected WxH, Wx or xH", s)
+		}
+		return n, nil
+	}
+	width, err := side(w)
+	if err != nil {
+		return 0, 0, err
+	}
+	height, err := side(h)
+	return width, height, err
+}
+
+// plan returns the size an image of width × height is scaled to and the
+// part of the scaled image that is kept, which is all of it unless the fit
+// is cover.
+func (r *resize) plan(width, height int) (image.Point, image.Rectangle) {
+	w, h := float64(width), float64(height)
+	var scale float64
+	switch {
+	case r.height == 0:
+		scale = float64(r.width) / w
+	case r.width == 0:
+		scale = float64(r.height) / h
+	case r.fit == fitFill:
+		return image.Pt(r.width, r.height), image.Rect(0, 0, r.width, r.height)
+	case r.fit == fitCover:
+		scale = math.Max(float64(r.width)/w, float64(r.height)/h)
+	default:
+		scale = math.Min(float64(r.width)/w, float64(r.height)/h)
+	}
+	if r.fit == fitInside && scale > 1 {
+		scale = 1
+	}
+
+	scaled := image.Pt(max(int(math.Round(w*scale)), 1), max(int(math.Round(h*scale)), 1))
+	if r.width != 0 && r.height != 0 {
+		if r.fit == fitCover {
+			// Rounding must not leave the scaled image short of the box.
+			scaled = image.Pt(max(scaled.X, r.width), max(scaled.Y, r.height))
+			crop := image.Rect(0, 0, r.width, r.height)
+			return scaled, crop.Add(image.Pt((scaled.X-r.width)/2, (scaled.Y-r.height)/2))
+		}
+		scaled = image.Pt(min(scaled.X, r.width), min(scaled.Y, r.height))
+	}
+	return scaled, image.Rectangle{Max: scaled}
+}
+
+// apply scales img according to plan.
+func (r *resize) apply(img image.Image) image.Image {
+	b := img.Bounds()
+	scaled, crop := r.plan(b.Dx(), b.Dy())
+	if scaled == b.Size() && crop.Size() == scaled {
+		return img
+	}
+	m := resample(img, scaled.X, scaled.Y, r.filter)
+	if crop.Size() == scaled {
+		return m
+	}
+	return cropImage(m, crop)
+}
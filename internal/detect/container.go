@@ -0,0 +1,167 @@
+package detect
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+)
+
+// riffTypes maps the form types of RIFF files to types.
+var riffTypes = map[string]Match{
+	"WEBP": {MimeType: "image/webp", Ext: ".webp"},
+	"WAVE": {MimeType: "audio/wav", Ext: ".wav"},
+	"AVI ": {MimeType: "video/x-msvideo", Ext: ".avi"},
+}
+
+// riff reads the form type of a RIFF file.
+func riff(header []byte) (Match, bool) {
+	if len(header) < 12 {
+		return Match{}, false
+	}
+	m, ok := riffTypes[string(header[8:12])]
+	m.Confidence = High
+	return m, ok
+}
+
+// brands maps ISO base media file brands to types. Files list several
+// compatible brands, so the types are ordered from the most to the least
+// specific.
+var brands = []struct {
+	brands []string
+	match  Match
+}{
+	{[]string{"avif", "avis"}, Match{MimeType: "image/avif", Ext: ".avif"}},
+	{[]string{"heic", "heix", "heim", "heis", "hevc", "hevx"}, Match{MimeType: "image/heic", Ext: ".heic"}},
+	{[]string{"crx "}, Match{MimeType: "image/x-canon-cr3", Ext: ".cr3"}},
+	{[]string{"mif1", "msf1"}, Match{MimeType: "image/heif", Ext: ".heif"}},
+	{[]string{"qt  "}, Match{MimeType: "video/quicktime", Ext: ".mov"}},
+	{[]string{"M4A ", "M4B "}, Match{MimeType: "audio/mp4", Ext: ".m4a"}},
+	{[]string{"M4V "}, Match{MimeType: "video/x-m4v", Ext: ".m4v"}},
+	{[]string{"3gp4", "3gp5", "3gp6", "3g2a"}, Match{MimeType: "video/3gpp", Ext: ".3gp"}},
+	{[]string{"isom", "iso2", "iso4", "iso5", "iso6", "mp41", "mp42", "avc1", "dash"}, Match{MimeType: "video/mp4", Ext: ".mp4"}},
+}
+
+// isoBMFF reads the brands in the ftyp box of an ISO base media file: the
+// major brand, a minor version and the compatible brands.
+func isoBMFF(header []byte) (Match, bool) {
+	size := int(binary.BigEndian.Uint32(header))
+	if size < 16 || size%4 != 0 || len(header) < 16 {
+		return Match{}, false
+	}
+	box := header[:min(size, len(header))]
+	found := map[string]bool{string(box[8:12]): true}
+	for i := 16; i+4 <= len(box); i += 4 {
+		found[string(box[i:i+4])] = true
+	}
+
+	for _, b := range brands {
+		for _, brand := range b.brands {
+			if found[brand] {
+				m := b.match
+				m.Confidence = High
+				return m, true
+			}
+		}
+	}
+	return Match{}, false
+}
+
+var (
+	webm = Match{MimeType: "video/webm", Ext: ".webm", Confidence: High}
+	mkv  = Match{MimeType: "video/x-matroska", Ext: ".mkv", Confidence: High}
+)
+
+// matroska reads the document type in the EBML header, which tells WebM
+// from other Matroska files.
+func matroska(header []byte) (Match, bool) {
+	// The DocType element ID is followed by a one byte size.
+	if i := bytes.Index(header[:min(len(header), 64)], []byte("\x42\x82")); i >= 0 && i+3 < len(header) {
+		size := int(header[i+2] &^ 0x80)
+		docType := string(header[i+3 : min(i+3+size, len(header))])
+		if docType == "webm" {
+			return webm, true
+		}
+	}
+	return mkv, true
+}
+
+// zipMimeTypes are the values of the "mimetype" entry that OpenDocument and
+// EPUB files start with.
+var zipMimeTypes = map[string]string{
+	"application/epub+zip":                            ".epub",
+	"application/vnd.oasis.opendocument.text":         ".odt",
+	"application/vnd.oasis.opendocument.spreadsheet":  ".ods",
+	"application/vnd.oasis.opendocument.presentation": ".odp",
+	"application/vnd.oasis.opendocument.graphics":     ".odg",
+}
+
+// zipPrefixes map the names of entries to the ZIP based formats they
+// belong to.
+var zipPrefixes = []struct {
+	prefix string
+	match  Match
+}{
+	{"word/", Match{MimeType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document", Ext: ".docx"}},
+	{"xl/", Match{MimeType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", Ext: ".xlsx"}},
+	{"ppt/", Match{MimeType: "application/vnd.openxmlformats-officedocument.presentationml.presentation", Ext: ".pptx"}},
+	{"META-INF/MANIFEST.MF", Match{MimeType: "application/java-archive", Ext: ".jar"}},
+}
+
+// zip walks the local file headers within the header to find the entries
+// that identify formats based on ZIP. It stops at the first entry whose
+// size is only given after its data.
+func zip(header []byte) (Match, bool) {
+	for p := 0; p+30 <= len(header) && string(header[p:p+4]) == "PK\x03\x04"; {
+		flags := binary.LittleEndian.Uint16(header[p+6:])
+		method := binary.LittleEndian.Uint16(header[p+8:])
+		compressed := int(binary.LittleEndian.Uint32(header[p+18:]))
+		nameLen := int(binary.LittleEndian.Uint16(header[p+26:]))
+		extraLen := int(binary.LittleEndian.Uint16(header[p+28:]))
+		data := p + 30 + nameLen + extraLen
+		if data > len(header) {
+			break
+		}
+		name := string(header[p+30 : p+30+nameLen])
+
+		// OpenDocument and EPUB files store their MIME type uncompressed
+		// in the first entry.
+		if p == 0 && name == "mimetype" && method == 0 {
+			content := string(header[data:min(data+compressed, len(header))])
+			if ext, ok := zipMimeTypes[content]; ok {
+				return Match{MimeType: content, Ext: ext, Confidence: High}, true
+			}
+		}
+		for _, z := range zipPrefixes {
+			if strings.HasPrefix(name, z.prefix) {
+				m := z.match
+				m.Confidence = High
+				return m, true
+			}
+		}
+
+		if flags&0x08 != 0 {
+			break
+		}
+		p = data + compressed
+	}
+	return Match{MimeType: "application/zip", Ext: ".zip", Confidence: High}, true
+}
+
+// init adds the types that containers are inspected for to the MIME type
+// table.
+func init() {
+	for _, m := range riffTypes {
+		RegisterType(m.MimeType, m.Ext)
+	}
+	for _, b := range brands {
+		RegisterType(b.match.MimeType, b.match.Ext)
+	}
+	RegisterType(webm.MimeType, webm.Ext)
+	RegisterType(mkv.MimeType, mkv.Ext)
+	for mimeType, ext := range zipMimeTypes {
+		RegisterType(mimeType, ext)
+	}
+	for _, z := range zipPrefixes {
+		RegisterType(z.match.MimeType, z.match.Ext)
+	}
+}
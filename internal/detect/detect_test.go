@@ -0,0 +1,157 @@
+package detect
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// riffFile returns the header of a RIFF file of the given form type.
+func riffFile(form string) []byte {
+	b := []byte("RIFF")
+	b = binary.LittleEndian.AppendUint32(b, 1000)
+	b = append(b, form...)
+	return append(b, "VP8 \x10\x00\x00\x00"...)
+}
+
+// ftyp returns the header of an ISO base media file with the given major
+// and compatible brands.
+func ftyp(major string, compatible ...string) []byte {
+	b := binary.BigEndian.AppendUint32(nil, uint32(16+4*len(compatible)))
+	b = append(b, "ftyp"+major+"\x00\x00\x00\x00"...)
+	for _, c := range compatible {
+		b = append(b, c...)
+	}
+	return append(b, "\x00\x00\x00\x08free"...)
+}
+
+// ebml returns the EBML header of a Matroska file with the given document
+// type.
+func ebml(docType string) []byte {
+	b := []byte("\x1a\x45\xdf\xa3\xa3\x42\x86\x81\x01\x42\xf7\x81\x01")
+	b = append(b, 0x42, 0x82, byte(0x80|len(docType)))
+	return append(b, docType...)
+}
+
+// zipEntry returns a stored ZIP entry.
+func zipEntry(name, content string) []byte {
+	le := binary.LittleEndian
+	b := []byte("PK\x03\x04\x14\x00")
+	b = le.AppendUint16(b, 0) // flags
+	b = le.AppendUint16(b, 0) // method: stored
+	b = le.AppendUint32(b, 0) // time and date
+	b = le.AppendUint32(b, 0) // CRC-32
+	b = le.AppendUint32(b, uint32(len(content)))
+	b = le.AppendUint32(b, uint32(len(content)))
+	b = le.AppendUint16(b, uint16(len(name)))
+	b = le.AppendUint16(b, 0)
+	b = append(b, name...)
+	return append(b, content...)
+}
+
+func join(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+func TestDetect(t *testing.T) {
+	ico := []byte("\x00\x00\x01\x00\x01\x00\x10\x10\x00\x00\x01\x00\x20\x00\x68\x04\x00\x00\x16\x00\x00\x00")
+
+	tests := []struct {
+		name   string
+		header []byte
+		want   Match
+	}{
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), Match{"image/png", ".png", High}},
+		{"jpeg", []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00"), Match{"image/jpeg", ".jpeg", High}},
+		{"gif", []byte("GIF89a\x01\x00\x01\x00"), Match{"image/gif", ".gif", High}},
+		{"jxl codestream", []byte("\xff\x0a\xfa\x7f"), Match{"image/jxl", ".jxl", Medium}},
+
+		{"riff webp", riffFile("WEBP"), Match{"image/webp", ".webp", High}},
+		{"riff wave", riffFile("WAVE"), Match{"audio/wav", ".wav", High}},
+		{"riff avi", riffFile("AVI "), Match{"video/x-msvideo", ".avi", High}},
+		{"riff unknown", riffFile("ABCD"), Match{"application/octet-stream", "", None}},
+		{"riff truncated", []byte("RIFF\x00\x00\x00\x00WE"), Match{"application/octet-stream", "", None}},
+
+		{"ftyp avif", ftyp("avif", "mif1", "miaf"), Match{"image/avif", ".avif", High}},
+		{"ftyp heic", ftyp("heic", "mif1", "heic"), Match{"image/heic", ".heic", High}},
+		// The most specific compatible brand wins over the major brand.
+		{"ftyp heif with heic", ftyp("mif1", "heic"), Match{"image/heic", ".heic", High}},
+		{"ftyp mp4", ftyp("isom", "isom", "iso2", "avc1", "mp41"), Match{"video/mp4", ".mp4", High}},
+		{"ftyp mov", ftyp("qt  ", "qt  "), Match{"video/quicktime", ".mov", High}},
+		{"ftyp unknown", ftyp("abcd", "efgh"), Match{"application/octet-stream", "", None}},
+		{"ftyp truncated", ftyp("avif")[:12], Match{"application/octet-stream", "", None}},
+
+		{"matroska", ebml("matroska"), Match{"video/x-matroska", ".mkv", High}},
+		{"webm", ebml("webm"), Match{"video/webm", ".webm", High}},
+		{"ebml truncated", ebml("webm")[:6], Match{"video/x-matroska", ".mkv", High}},
+
+		{"zip", zipEntry("a.txt", "hello"), Match{"application/zip", ".zip", High}},
+		{"empty zip", []byte("PK\x05\x06" + strings.Repeat("\x00", 18)), Match{"application/zip", ".zip", High}},
+		{"docx", join(zipEntry("[Content_Types].xml", "<Types/>"), zipEntry("word/document.xml", "<w/>")), Match{"application/vnd.openxmlformats-officedocument.wordprocessingml.document", ".docx", High}},
+		{"xlsx", join(zipEntry("[Content_Types].xml", "<Types/>"), zipEntry("xl/workbook.xml", "<x/>")), Match{"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", ".xlsx", High}},
+		{"pptx", join(zipEntry("[Content_Types].xml", "<Types/>"), zipEntry("ppt/presentation.xml", "<p/>")), Match{"application/vnd.openxmlformats-officedocument.presentationml.presentation", ".pptx", High}},
+		{"odt", zipEntry("mimetype", "application/vnd.oasis.opendocument.text"), Match{"application/vnd.oasis.opendocument.text", ".odt", High}},
+		// The entry naming the type lies beyond the header.
+		{"zip truncated", zipEntry("[Content_Types].xml", "<Types/>")[:40], Match{"application/zip", ".zip", High}},
+
+		{"svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`), Match{"image/svg+xml", ".svg", Medium}},
+		{"svg with declaration", []byte("\xef\xbb\xbf<?xml version=\"1.0\"?>\n<!-- drawn by hand -->\n<svg/>"), Match{"image/svg+xml", ".svg", Medium}},
+		{"svg with doctype", []byte("<!DOCTYPE svg PUBLIC \"-//W3C//DTD SVG 1.1//EN\">\n<svg/>"), Match{"image/svg+xml", ".svg", Medium}},
+		{"html mentioning svg", []byte("<html><body><svg/></body></html>"), Match{"text/html; charset=utf-8", "", Low}},
+
+		{"ico", ico, Match{"image/x-icon", ".ico", Medium}},
+		// net/http still sniffs these as icons, but only with Low confidence.
+		{"ico without images", []byte("\x00\x00\x01\x00\x00\x00" + strings.Repeat("\x00", 16)), Match{"image/x-icon", ".ico", Low}},
+		{"ico truncated", ico[:12], Match{"image/x-icon", ".ico", Low}},
+
+		{"text", []byte("just some text\n"), Match{"text/plain; charset=utf-8", "", Low}},
+		{"empty", nil, Match{"text/plain; charset=utf-8", "", Low}},
+		// Without the binary bytes of its signature, the start of a PNG
+		// file looks like text.
+		{"truncated png", []byte("\x89PN"), Match{"text/plain; charset=utf-8", "", Low}},
+		{"binary", []byte("\x00\x01\x02\x03\x04\x05\x06\x07"), Match{"application/octet-stream", "", None}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Detect(tt.header); got != tt.want {
+				t.Errorf("Detect = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestInspectedTypes checks that the types reported by Inspect functions
+// have an extension in the MIME type table.
+func TestInspectedTypes(t *testing.T) {
+	for mimeType, want := range map[string]string{
+		"image/bmp":              ".bmp",
+		"image/tiff":             ".tiff",
+		"image/x-icon":           ".ico",
+		"image/svg+xml":          ".svg",
+		"application/postscript": ".ps",
+		"image/avif":             ".avif",
+		"video/webm":             ".webm",
+		"application/zip":        ".zip",
+	} {
+		if got, ok := ExtensionFromMimeType(mimeType); !ok || got != want {
+			t.Errorf("ExtensionFromMimeType(%q) = %q, %t, want %q", mimeType, got, ok, want)
+		}
+	}
+	for _, ext := range []string{".cr2", ".cur", ".eps", ".mkv", ".docx"} {
+		if !knownExts[ext] {
+			t.Errorf("%s is not a known extension", ext)
+		}
+	}
+}
+
+func TestConfidenceString(t *testing.T) {
+	for c, want := range map[Confidence]string{None: "none", Low: "low", Medium: "medium", High: "high", 7: "none"} {
+		if got := c.String(); got != want {
+			t.Errorf("Confidence(%d).String() = %q, want %q", int(c), got, want)
+		}
+	}
+}
@@ -7,7 +7,21 @@ var mimeTypeToExt = map[string]string{
 	"image/webp": ".webp",
 }
 
+// knownExts holds the extensions of all types in the table and of types
+// that share a MIME type with another, such as ".eps" and ".ps".
+var knownExts = map[string]bool{}
+
 func ExtensionFromMimeType(mimeType string) (string, bool) {
 	ext, ok := mimeTypeToExt[mimeType]
 	return ext, ok
 }
+
+// MimeTypes returns a copy of the table mapping detected MIME types to
+// file extensions.
+func MimeTypes() map[string]string {
+	all := make(map[string]string, len(mimeTypeToExt))
+	for k, v := range mimeTypeToExt {
+		all[k] = v
+	}
+	return all
+}
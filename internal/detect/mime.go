@@ -1,24 +1,42 @@
 package detect
 
 import (
-	"net/http"
+	"bufio"
+	"io"
 	"os"
 )
 
-func MimeType(filePath string) (string, error) {
+// sniffLen is the number of bytes used to detect the content type. It is
+// enough to see past the first few entries of ZIP based documents.
+const sniffLen = 4096
+
+// DetectFile detects the type of the file at filePath.
+func DetectFile(filePath string) (Match, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
-		return "", err
+		return Match{}, err
 	}
 	defer file.Close()
 
-	// The first 512 bytes are used to detect the content type.
-	buffer := make([]byte, 512)
-	_, err = file.Read(buffer)
-	if err != nil {
-		return "", err
+	buffer := make([]byte, sniffLen)
+	n, err := io.ReadFull(file, buffer)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return Match{}, err
 	}
+	return Detect(buffer[:n]), nil
+}
 
-	contentType := http.DetectContentType(buffer)
-	return contentType, nil
+// DetectReader detects the type of a stream. Because the sniffed bytes are
+// consumed from r, the returned reader must be used in its place; it yields
+// the complete stream including the sniffed prefix.
+func DetectReader(r io.Reader) (Match, io.Reader, error) {
+	br := bufio.NewReaderSize(r, sniffLen)
+	buffer, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF {
+		return Match{}, nil, err
+	}
+	if len(buffer) == 0 {
+		return Match{}, nil, io.ErrUnexpectedEOF
+	}
+	return Detect(buffer), br, nil
 }
@@ -0,0 +1,34 @@
+package detect
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/renja-g/convert/internal/alias"
+)
+
+// Mismatch reports a file whose extension names a different type than its
+// content.
+type Mismatch struct {
+	// Ext is the extension of the file name.
+	Ext string
+	// Match is the type detected from the content.
+	Match Match
+}
+
+func (m *Mismatch) Error() string {
+	return fmt.Sprintf("extension %s does not match the content (%s)", m.Ext, m.Match.MimeType)
+}
+
+// CheckExtension compares the extension of path with the type m detected
+// from its content. It returns nil if they agree, if the extension is not
+// that of a known type, as with ".bin", or if m is only a guess.
+func CheckExtension(path string, m Match) *Mismatch {
+	ext := filepath.Ext(path)
+	resolved := alias.Resolve(strings.ToLower(ext))
+	if m.Confidence < Medium || m.Ext == "" || !knownExts[resolved] || resolved == m.Ext {
+		return nil
+	}
+	return &Mismatch{Ext: ext, Match: m}
+}
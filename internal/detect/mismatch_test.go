@@ -0,0 +1,39 @@
+package detect
+
+import "testing"
+
+func TestCheckExtension(t *testing.T) {
+	jpeg := Match{MimeType: "image/jpeg", Ext: ".jpeg", Confidence: High}
+	tests := []struct {
+		path     string
+		match    Match
+		mismatch bool
+	}{
+		{"photo.jpeg", jpeg, false},
+		// Aliases and upper case name the same type.
+		{"photo.jpg", jpeg, false},
+		{"PHOTO.JPG", jpeg, false},
+		{"scan.TIF", Match{MimeType: "image/tiff", Ext: ".tiff", Confidence: High}, false},
+		{"photo.png", jpeg, true},
+		{"dir.png/photo.webp", jpeg, true},
+		// Extensions of unknown types say nothing about the content.
+		{"photo.bin", jpeg, false},
+		{"photo", jpeg, false},
+		// Neither do guesses and types without an extension.
+		{"notes.png", Match{MimeType: "text/plain; charset=utf-8", Confidence: Low}, false},
+		{"notes.png", Match{MimeType: "image/jpeg", Ext: ".jpeg", Confidence: Low}, false},
+		{"legacy.png", Match{MimeType: "application/x-ole-storage", Confidence: Medium}, false},
+		{"archive.png", Match{MimeType: "application/zip", Ext: ".zip", Confidence: High}, true},
+	}
+	for _, tt := range tests {
+		m := CheckExtension(tt.path, tt.match)
+		switch {
+		case !tt.mismatch && m != nil:
+			t.Errorf("CheckExtension(%q, %s) = %v, want nil", tt.path, tt.match.Ext, m)
+		case tt.mismatch && m == nil:
+			t.Errorf("CheckExtension(%q, %s) = nil, want a mismatch", tt.path, tt.match.Ext)
+		case tt.mismatch && (m.Match != tt.match || m.Ext != tt.path[len(tt.path)-len(m.Ext):]):
+			t.Errorf("CheckExtension(%q, %s) = %+v", tt.path, tt.match.Ext, m)
+		}
+	}
+}
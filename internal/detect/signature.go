@@ -0,0 +1,116 @@
+package detect
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+)
+
+// Confidence says how certain a detection is.
+type Confidence int
+
+const (
+	// None means the content was not recognised.
+	None Confidence = iota
+	// Low is a guess from text content or from net/http's sniffing.
+	Low
+	// Medium is a match of a short or common magic number.
+	Medium
+	// High is a match of a distinctive magic number, or of a container
+	// whose structure was checked.
+	High
+)
+
+func (c Confidence) String() string {
+	switch c {
+	case Low:
+		return "low"
+	case Medium:
+		return "medium"
+	case High:
+		return "high"
+	}
+	return "none"
+}
+
+// Match is the detected type of some content.
+type Match struct {
+	MimeType string
+	// Ext is the usual file extension of the type, e.g. ".png", or "" if
+	// the type does not tell, as for an unrecognised ZIP subtype.
+	Ext        string
+	Confidence Confidence
+}
+
+// Signature recognises a file type by the bytes at the start of a file.
+type Signature struct {
+	// Magic must appear at Offset. A signature without Magic is tried on
+	// any content and must have an Inspect function.
+	Offset int
+	Magic  []byte
+	// The match reported if Magic is found and there is no Inspect
+	// function.
+	Match
+	// Inspect, if set, is called with the header if Magic is found. It
+	// returns the actual match, for example the subtype of a container,
+	// or false to reject the header.
+	Inspect func(header []byte) (Match, bool)
+}
+
+// signatures are tried in order; the first of the most confident matches
+// wins.
+var signatures []Signature
+
+// Register adds a signature to the database and its MIME type and
+// extension to the table used by ExtensionFromMimeType. It must be called
+// before detection starts, usually from an init function. Types that an
+// Inspect function reports should be added with RegisterType.
+func Register(s Signature) {
+	signatures = append(signatures, s)
+	RegisterType(s.MimeType, s.Ext)
+}
+
+// RegisterType adds a MIME type and its usual extension to the table used
+// by ExtensionFromMimeType and CheckExtension. The first extension added
+// for a MIME type is kept.
+func RegisterType(mimeType, ext string) {
+	if _, ok := mimeTypeToExt[mimeType]; !ok && mimeType != "" && ext != "" {
+		mimeTypeToExt[mimeType] = ext
+	}
+	if ext != "" {
+		knownExts[ext] = true
+	}
+}
+
+// Detect returns the type of the content that starts with header. Types
+// without a signature are left to net/http's sniffing, with Low
+// confidence. The header is the start of the content; DetectFile and
+// DetectReader pass its first 4 KiB.
+func Detect(header []byte) Match {
+	var best Match
+	for _, s := range signatures {
+		if s.Magic != nil && !bytes.HasPrefix(header[min(s.Offset, len(header)):], s.Magic) {
+			continue
+		}
+		m, ok := s.Match, true
+		if s.Inspect != nil {
+			m, ok = s.Inspect(header)
+		}
+		if ok && m.Confidence > best.Confidence {
+			best = m
+		}
+	}
+	if best.Confidence != None {
+		return best
+	}
+
+	mimeType := http.DetectContentType(header)
+	// Parameters such as the charset of text don't change the extension.
+	base, _, _ := strings.Cut(mimeType, ";")
+	ext, _ := ExtensionFromMimeType(base)
+	confidence := Low
+	if mimeType == "application/octet-stream" {
+		confidence = None
+	}
+	return Match{MimeType: mimeType, Ext: ext, Confidence: confidence}
+}
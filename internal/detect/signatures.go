@@ -0,0 +1,155 @@
+package detect
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// magic returns a signature that matches magic at the start of the content.
+func magic(magic, mimeType, ext string, confidence Confidence) Signature {
+	return Signature{Magic: []byte(magic), Match: Match{MimeType: mimeType, Ext: ext, Confidence: confidence}}
+}
+
+// inspect returns a signature that calls fn if magic is found at offset.
+func inspect(offset int, magic string, fn func(header []byte) (Match, bool)) Signature {
+	return Signature{Offset: offset, Magic: []byte(magic), Inspect: fn}
+}
+
+// builtin is the signature database. Specific signatures come before the
+// generic ones they overlap with.
+var builtin = []Signature{
+	// Raster images.
+	magic("\x89PNG\r\n\x1a\n", "image/png", ".png", High),
+	magic("\xff\xd8\xff", "image/jpeg", ".jpeg", High),
+	magic("GIF87a", "image/gif", ".gif", High),
+	magic("GIF89a", "image/gif", ".gif", High),
+	inspect(0, "BM", bmp),
+	inspect(0, "II*\x00", tiff),
+	inspect(0, "MM\x00*", tiff),
+	inspect(0, "\x00\x00\x01\x00", icon("image/x-icon", ".ico")),
+	inspect(0, "\x00\x00\x02\x00", icon("image/x-icon", ".cur")),
+	magic("8BPS", "image/vnd.adobe.photoshop", ".psd", High),
+	magic("qoif", "image/qoi", ".qoi", High),
+	magic("\x00\x00\x00\x0cJXL \r\n\x87\n", "image/jxl", ".jxl", High),
+	magic("\xff\x0a", "image/jxl", ".jxl", Medium),
+	magic("\x00\x00\x00\x0cjP  \r\n\x87\n", "image/jp2", ".jp2", High),
+	{Inspect: svg},
+
+	// Containers: RIFF (WebP, WAV, AVI), ISO base media (HEIC, AVIF,
+	// MP4, QuickTime), Matroska and ZIP (OOXML, OpenDocument, EPUB, JAR).
+	inspect(0, "RIFF", riff),
+	inspect(4, "ftyp", isoBMFF),
+	inspect(0, "\x1a\x45\xdf\xa3", matroska),
+	inspect(0, "PK\x03\x04", zip),
+	magic("PK\x05\x06", "application/zip", ".zip", High),
+
+	// Documents.
+	magic("%PDF-", "application/pdf", ".pdf", High),
+	inspect(0, "%!PS", postScript),
+	magic("\xc5\xd0\xd3\xc6", "application/postscript", ".eps", High),
+	magic("{\\rtf", "application/rtf", ".rtf", High),
+	// Legacy Office documents and other compound files share a header.
+	magic("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1", "application/x-ole-storage", "", Medium),
+
+	// Archives and compressed files.
+	magic("\x1f\x8b\x08", "application/gzip", ".gz", Medium),
+	magic("BZh", "application/x-bzip2", ".bz2", Medium),
+	magic("\xfd7zXZ\x00", "application/x-xz", ".xz", High),
+	magic("7z\xbc\xaf\x27\x1c", "application/x-7z-compressed", ".7z", High),
+	magic("Rar!\x1a\x07", "application/vnd.rar", ".rar", High),
+	magic("\x28\xb5\x2f\xfd", "application/zstd", ".zst", High),
+	{Offset: 257, Magic: []byte("ustar"), Match: Match{MimeType: "application/x-tar", Ext: ".tar", Confidence: High}},
+
+	// Audio.
+	magic("fLaC", "audio/flac", ".flac", High),
+	magic("OggS", "audio/ogg", ".ogg", High),
+	magic("ID3", "audio/mpeg", ".mp3", Medium),
+	magic("MThd", "audio/midi", ".mid", High),
+
+	// Fonts.
+	magic("wOFF", "font/woff", ".woff", High),
+	magic("wOF2", "font/woff2", ".woff2", High),
+	magic("OTTO", "font/otf", ".otf", High),
+	magic("\x00\x01\x00\x00", "font/ttf", ".ttf", Medium),
+}
+
+// inspected are the types reported by the Inspect functions of builtin.
+// PostScript comes before EPS so that it keeps the ".ps" extension.
+var inspected = []Match{
+	{MimeType: "image/bmp", Ext: ".bmp"},
+	{MimeType: "image/tiff", Ext: ".tiff"},
+	{MimeType: "image/x-canon-cr2", Ext: ".cr2"},
+	{MimeType: "image/x-icon", Ext: ".ico"},
+	{MimeType: "image/x-icon", Ext: ".cur"},
+	{MimeType: "image/svg+xml", Ext: ".svg"},
+	{MimeType: "application/postscript", Ext: ".ps"},
+	{MimeType: "application/postscript", Ext: ".eps"},
+}
+
+func init() {
+	for _, m := range inspected {
+		RegisterType(m.MimeType, m.Ext)
+	}
+	for _, s := range builtin {
+		Register(s)
+	}
+}
+
+// bmp checks the size of the info header that follows the file header.
+func bmp(header []byte) (Match, bool) {
+	if len(header) < 18 {
+		return Match{}, false
+	}
+	switch binary.LittleEndian.Uint32(header[14:]) {
+	case 12, 40, 52, 56, 64, 108, 124:
+		return Match{MimeType: "image/bmp", Ext: ".bmp", Confidence: High}, true
+	}
+	return Match{}, false
+}
+
+// tiff tells raw camera files that are TIFF files from plain TIFF.
+func tiff(header []byte) (Match, bool) {
+	if len(header) >= 11 && string(header[8:11]) == "CR\x02" {
+		return Match{MimeType: "image/x-canon-cr2", Ext: ".cr2", Confidence: High}, true
+	}
+	return Match{MimeType: "image/tiff", Ext: ".tiff", Confidence: High}, true
+}
+
+// icon checks that the header of an icon or cursor file announces at least
+// one image.
+func icon(mimeType, ext string) func([]byte) (Match, bool) {
+	return func(header []byte) (Match, bool) {
+		// The count is followed by 16 byte entries whose fourth byte is
+		// reserved.
+		if len(header) < 22 || binary.LittleEndian.Uint16(header[4:]) == 0 || header[9] != 0 {
+			return Match{}, false
+		}
+		return Match{MimeType: mimeType, Ext: ext, Confidence: Medium}, true
+	}
+}
+
+// svg recognises SVG documents by their root element, which may follow an
+// XML declaration, comments and a document type declaration.
+func svg(header []byte) (Match, bool) {
+	text := bytes.TrimPrefix(header, []byte("\xef\xbb\xbf"))
+	text = bytes.TrimLeft(text, " \t\r\n")
+	if !bytes.HasPrefix(text, []byte("<")) || !bytes.Contains(text, []byte("<svg")) {
+		return Match{}, false
+	}
+	for _, prefix := range []string{"<?xml", "<!--", "<!DOCTYPE svg", "<svg"} {
+		if bytes.HasPrefix(text, []byte(prefix)) {
+			return Match{MimeType: "image/svg+xml", Ext: ".svg", Confidence: Medium}, true
+		}
+	}
+	return Match{}, false
+}
+
+// postScript tells Encapsulated PostScript from other PostScript files by
+// the header comment.
+func postScript(header []byte) (Match, bool) {
+	line, _, _ := bytes.Cut(header, []byte("\n"))
+	if bytes.Contains(line, []byte("EPSF")) {
+		return Match{MimeType: "application/postscript", Ext: ".eps", Confidence: High}, true
+	}
+	return Match{MimeType: "application/postscript", Ext: ".ps", Confidence: High}, true
+}
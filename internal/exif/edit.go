@@ -0,0 +1,113 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// ResetOrientation returns a copy of the EXIF block data with the
+// orientation set to 1, for images whose pixels have been rotated and
+// flipped to match it.
+func ResetOrientation(data []byte) ([]byte, error) {
+	x, err := Parse(bytes.Clone(data))
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := x.tags[TagOrientation]; ok && e.typ == typeShort && e.count > 0 {
+		x.order.PutUint16(e.value, 1)
+	}
+	return x.tiff, nil
+}
+
+// StripGPS returns a copy of the EXIF block data without the GPS IFD. The
+// IFD and its values are overwritten with zeros, so no trace of the
+// location remains in the block.
+func StripGPS(data []byte) ([]byte, error) {
+	x, err := Parse(bytes.Clone(data))
+	if err != nil {
+		return nil, err
+	}
+	offset, ok := x.Uint(TagGPSIFD)
+	if !ok {
+		return x.tiff, nil
+	}
+	for _, e := range x.gps {
+		clear(e.value)
+	}
+	n := int(x.order.Uint16(x.tiff[offset:]))
+	clear(x.tiff[offset:min(int(offset)+2+12*n+4, len(x.tiff))])
+	x.removeEntry(x.ifd0, TagGPSIFD)
+	return x.tiff, nil
+}
+
+// removeEntry deletes the entry for tag from the IFD at offset, moving the
+// following entries and the offset of the next IFD up.
+func (x *Exif) removeEntry(offset uint32, tag uint16) {
+	n := int(x.order.Uint16(x.tiff[offset:]))
+	start := int(offset) + 2
+	end := min(start+12*n+4, len(x.tiff))
+	for i := 0; i < n; i++ {
+		e := start + 12*i
+		if x.order.Uint16(x.tiff[e:]) == tag {
+			copy(x.tiff[e:], x.tiff[e+12:end])
+			clear(x.tiff[end-12 : end])
+			x.order.PutUint16(x.tiff[offset:], uint16(n-1))
+			return
+		}
+	}
+}
+
+// Copyright returns a new EXIF block holding only the artist and copyright
+// tags of data, or nil if it has neither.
+func Copyright(data []byte) ([]byte, error) {
+	x, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	var tags []uint16
+	var values [][]byte
+	for _, tag := range []uint16{TagArtist, TagCopyright} {
+		if e, ok := x.tags[tag]; ok && e.typ == typeASCII && e.count > 0 {
+			tags = append(tags, tag)
+			values = append(values, e.value)
+		}
+	}
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	return buildASCII(tags, values), nil
+}
+
+// buildASCII writes a little-endian EXIF block whose only IFD holds the
+// given ASCII tags, which must be in ascending order.
+func buildASCII(tags []uint16, values [][]byte) []byte {
+	le := binary.LittleEndian
+	out := le.AppendUint32([]byte("II*\x00"), 8)
+	out = le.AppendUint16(out, uint16(len(tags)))
+
+	// Values of more than four bytes follow the IFD.
+	valueOffset := len(out) + 12*len(tags) + 4
+	var extra []byte
+	for i, tag := range tags {
+		v := values[i]
+		if !bytes.HasSuffix(v, []byte{0}) {
+			v = append(bytes.Clone(v), 0)
+		}
+		out = le.AppendUint16(out, tag)
+		out = le.AppendUint16(out, typeASCII)
+		out = le.AppendUint32(out, uint32(len(v)))
+		if len(v) <= 4 {
+			out = append(out, v...)
+			out = append(out, make([]byte, 4-len(v))...)
+			continue
+		}
+		out = le.AppendUint32(out, uint32(valueOffset+len(extra)))
+		extra = append(extra, v...)
+		// Values start on word boundaries.
+		if len(extra)%2 == 1 {
+			extra = append(extra, 0)
+		}
+	}
+	out = le.AppendUint32(out, 0)
+	return append(out, extra...)
+}
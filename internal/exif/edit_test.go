@@ -0,0 +1,165 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// byteOrder reads and appends integers in either byte order.
+type byteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// gpsBlock returns an EXIF block in the given byte order whose IFD0 holds
+// the make, orientation 6 and a GPS IFD with a location, 52°31'N 13°24'E.
+func gpsBlock(order byteOrder) []byte {
+	var b []byte
+	if order == byteOrder(binary.LittleEndian) {
+		b = []byte("II")
+	} else {
+		b = []byte("MM")
+	}
+	b = order.AppendUint16(b, 42)
+	b = order.AppendUint32(b, 8)
+
+	entry := func(b []byte, tag, typ uint16, count uint32, value []byte) []byte {
+		b = order.AppendUint16(b, tag)
+		b = order.AppendUint16(b, typ)
+		b = order.AppendUint32(b, count)
+		return append(b, value...)
+	}
+	short := func(v uint16) []byte { return order.AppendUint16(order.AppendUint16(nil, v), 0) }
+	long := func(v uint32) []byte { return order.AppendUint32(nil, v) }
+
+	// IFD0 at 8: three entries, then the make and the GPS IFD.
+	const makeOffset = 8 + 2 + 3*12 + 4
+	const gpsOffset = makeOffset + 6
+	b = order.AppendUint16(b, 3)
+	b = entry(b, TagMake, typeASCII, 6, long(makeOffset))
+	b = entry(b, TagOrientation, typeShort, 1, short(6))
+	b = entry(b, TagGPSIFD, typeLong, 1, long(gpsOffset))
+	b = order.AppendUint32(b, 0)
+	b = append(b, "Canon\x00"...)
+
+	// The GPS IFD: four entries, then the two coordinates.
+	const latOffset = gpsOffset + 2 + 4*12 + 4
+	const lonOffset = latOffset + 24
+	b = order.AppendUint16(b, 4)
+	b = entry(b, gpsLatitudeRef, typeASCII, 2, []byte("N\x00\x00\x00"))
+	b = entry(b, gpsLatitude, typeRational, 3, long(latOffset))
+	b = entry(b, gpsLongitudeRef, typeASCII, 2, []byte("E\x00\x00\x00"))
+	b = entry(b, gpsLongitude, typeRational, 3, long(lonOffset))
+	b = order.AppendUint32(b, 0)
+	for _, v := range []uint32{52, 1, 31, 1, 0, 1, 13, 1, 24, 1, 0, 1} {
+		b = order.AppendUint32(b, v)
+	}
+	return b
+}
+
+var byteOrders = map[string]byteOrder{
+	"little-endian": binary.LittleEndian,
+	"big-endian":    binary.BigEndian,
+}
+
+func TestStripGPS(t *testing.T) {
+	for name, order := range byteOrders {
+		t.Run(name, func(t *testing.T) {
+			data := gpsBlock(order)
+			original := bytes.Clone(data)
+			x, err := Parse(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if lat, lon, ok := x.GPS(); !ok || lat < 52.51 || lat > 52.52 || lon < 13.39 || lon > 13.41 {
+				t.Fatalf("GPS() of the input = %v, %v, %v", lat, lon, ok)
+			}
+
+			stripped, err := StripGPS(append([]byte(Header), data...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, original) {
+				t.Error("StripGPS modified its input")
+			}
+			x, err = Parse(stripped)
+			if err != nil {
+				t.Fatalf("parsing the result: %v", err)
+			}
+			if x.HasGPS() {
+				t.Error("the result still has a GPS IFD")
+			}
+			if _, ok := x.Uint(TagGPSIFD); ok {
+				t.Error("the result still has a GPS IFD entry")
+			}
+			if s, ok := x.String(TagMake); s != "Canon" {
+				t.Errorf("make = %q, %v; want Canon", s, ok)
+			}
+			if o := x.Orientation(); o != 6 {
+				t.Errorf("orientation = %d, want 6", o)
+			}
+			// The coordinates must not survive anywhere in the block.
+			for _, v := range []uint32{52, 31, 13, 24} {
+				if bytes.Contains(stripped, order.AppendUint32(order.AppendUint32(nil, v), 1)) {
+					t.Errorf("the result still holds the rational %d/1", v)
+				}
+			}
+		})
+	}
+}
+
+func TestStripGPSWithoutGPS(t *testing.T) {
+	data := buildASCII([]uint16{TagMake}, [][]byte{[]byte("Canon")})
+	stripped, err := StripGPS(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stripped, data) {
+		t.Errorf("StripGPS changed a block without GPS: %x, want %x", stripped, data)
+	}
+}
+
+func TestResetOrientation(t *testing.T) {
+	for name, order := range byteOrders {
+		t.Run(name, func(t *testing.T) {
+			data := gpsBlock(order)
+			reset, err := ResetOrientation(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			x, err := Parse(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if o := x.Orientation(); o != 6 {
+				t.Errorf("ResetOrientation modified its input: orientation %d", o)
+			}
+
+			x, err = Parse(reset)
+			if err != nil {
+				t.Fatalf("parsing the result: %v", err)
+			}
+			if o, ok := x.Uint(TagOrientation); !ok || o != 1 {
+				t.Errorf("orientation = %d, %v; want 1", o, ok)
+			}
+			if s, _ := x.String(TagMake); s != "Canon" {
+				t.Errorf("make = %q, want Canon", s)
+			}
+			if !x.HasGPS() {
+				t.Error("ResetOrientation dropped the GPS IFD")
+			}
+		})
+	}
+}
+
+func TestStripGPSInvalid(t *testing.T) {
+	for _, data := range [][]byte{nil, []byte("XX*\x00\x08\x00\x00\x00"), []byte("II*\x00\xff\x00\x00\x00")} {
+		if _, err := StripGPS(data); err == nil {
+			t.Errorf("StripGPS(%q) succeeded", data)
+		}
+		if _, err := ResetOrientation(data); err == nil {
+			t.Errorf("ResetOrientation(%q) succeeded", data)
+		}
+	}
+}
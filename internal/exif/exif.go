@@ -0,0 +1,272 @@
+// Package exif reads the tags of an EXIF block, the TIFF structure that
+// JPEG, PNG and WebP files embed to describe the camera and the shot.
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Tags read by this package. IFD0 and the Exif sub-IFD share one tag space.
+const (
+	TagMake             = 0x010f
+	TagModel            = 0x0110
+	TagOrientation      = 0x0112
+	TagSoftware         = 0x0131
+	TagDateTime         = 0x0132
+	TagArtist           = 0x013b
+	TagCopyright        = 0x8298
+	TagExposureTime     = 0x829a
+	TagFNumber          = 0x829d
+	TagExifIFD          = 0x8769
+	TagISO              = 0x8827
+	TagGPSIFD           = 0x8825
+	TagDateTimeOriginal = 0x9003
+	TagFocalLength      = 0x920a
+	TagLensModel        = 0xa434
+)
+
+// GPS tags, which live in their own IFD.
+const (
+	gpsLatitudeRef  = 1
+	gpsLatitude     = 2
+	gpsLongitudeRef = 3
+	gpsLongitude    = 4
+)
+
+// Field types and their sizes in bytes.
+const (
+	typeASCII    = 2
+	typeShort    = 3
+	typeLong     = 4
+	typeRational = 5
+)
+
+var typeSizes = [...]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+// Header is the prefix of EXIF blocks in JPEG APP1 segments.
+const Header = "Exif\x00\x00"
+
+// Exif holds the tags of an EXIF block.
+type Exif struct {
+	order binary.ByteOrder
+	tiff  []byte
+	ifd0  uint32
+	// tags holds IFD0 and the Exif sub-IFD, gps the GPS IFD.
+	tags, gps map[uint16]entry
+}
+
+type entry struct {
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+// Parse reads an EXIF block, with or without the JPEG "Exif\0\0" prefix.
+func Parse(data []byte) (*Exif, error) {
+	data = bytes.TrimPrefix(data, []byte(Header))
+	if len(data) < 8 {
+		return nil, errors.New("exif: truncated header")
+	}
+	x := &Exif{tiff: data}
+	switch string(data[:2]) {
+	case "II":
+		x.order = binary.LittleEndian
+	case "MM":
+		x.order = binary.BigEndian
+	default:
+		return nil, errors.New("exif: invalid byte order")
+	}
+	if x.order.Uint16(data[2:]) != 42 {
+		return nil, errors.New("exif: not a TIFF header")
+	}
+
+	var err error
+	x.ifd0 = x.order.Uint32(data[4:])
+	if x.tags, err = x.readIFD(x.ifd0); err != nil {
+		return nil, err
+	}
+	if offset, ok := x.Uint(TagExifIFD); ok {
+		sub, err := x.readIFD(offset)
+		if err != nil {
+			return nil, fmt.Errorf("exif: Exif IFD: %w", err)
+		}
+		for tag, e := range sub {
+			x.tags[tag] = e
+		}
+	}
+	if offset, ok := x.Uint(TagGPSIFD); ok {
+		if x.gps, err = x.readIFD(offset); err != nil {
+			return nil, fmt.Errorf("exif: GPS IFD: %w", err)
+		}
+	}
+	return x, nil
+}
+
+// readIFD reads the entries of the image file directory at offset.
+func (x *Exif) readIFD(offset uint32) (map[uint16]entry, error) {
+	if uint64(offset)+2 > uint64(len(x.tiff)) {
+		return nil, errors.New("exif: IFD offset out of range")
+	}
+	n := int(x.order.Uint16(x.tiff[offset:]))
+	start := int(offset) + 2
+	if start+12*n > len(x.tiff) {
+		return nil, errors.New("exif: truncated IFD")
+	}
+
+	entries := make(map[uint16]entry, n)
+	for i := 0; i < n; i++ {
+		e := x.tiff[start+12*i:]
+		tag, typ, count := x.order.Uint16(e), x.order.Uint16(e[2:]), x.order.Uint32(e[4:])
+		if int(typ) >= len(typeSizes) || typeSizes[typ] == 0 {
+			continue
+		}
+		// Values of up to four bytes are stored in place of their offset.
+		size := uint64(typeSizes[typ]) * uint64(count)
+		value := e[8:12]
+		if size > 4 {
+			valueOffset := uint64(x.order.Uint32(e[8:]))
+			if valueOffset+size > uint64(len(x.tiff)) {
+				continue
+			}
+			value = x.tiff[valueOffset : valueOffset+size]
+		}
+		entries[tag] = entry{typ: typ, count: count, value: value[:size]}
+	}
+	return entries, nil
+}
+
+// String returns the value of an ASCII tag.
+func (x *Exif) String(tag uint16) (string, bool) {
+	e, ok := x.tags[tag]
+	if !ok || e.typ != typeASCII {
+		return "", false
+	}
+	s := strings.TrimSpace(strings.TrimRight(string(e.value), "\x00"))
+	return s, s != ""
+}
+
+// Uint returns the first value of a SHORT or LONG tag.
+func (x *Exif) Uint(tag uint16) (uint32, bool) {
+	e, ok := x.tags[tag]
+	if !ok || e.count == 0 {
+		return 0, false
+	}
+	switch e.typ {
+	case typeShort:
+		return uint32(x.order.Uint16(e.value)), true
+	case typeLong:
+		return x.order.Uint32(e.value), true
+	}
+	return 0, false
+}
+
+// rationals returns the values of a RATIONAL tag as floats.
+func (x *Exif) rationals(tags map[uint16]entry, tag uint16) ([]float64, bool) {
+	e, ok := tags[tag]
+	if !ok || e.typ != typeRational || e.count == 0 {
+		return nil, false
+	}
+	values := make([]float64, e.count)
+	for i := range values {
+		num, den := x.order.Uint32(e.value[8*i:]), x.order.Uint32(e.value[8*i+4:])
+		if den == 0 {
+			return nil, false
+		}
+		values[i] = float64(num) / float64(den)
+	}
+	return values, true
+}
+
+// Orientation returns the orientation tag, 1 to 8, which says how the
+// stored pixels must be rotated and flipped for display. It is 1, the
+// identity, if the tag is missing or invalid.
+func (x *Exif) Orientation() int {
+	if o, ok := x.Uint(TagOrientation); ok && o >= 1 && o <= 8 {
+		return int(o)
+	}
+	return 1
+}
+
+// HasGPS reports whether the block records a location.
+func (x *Exif) HasGPS() bool {
+	return len(x.gps) > 0
+}
+
+// GPS returns the recorded location in decimal degrees.
+func (x *Exif) GPS() (lat, lon float64, ok bool) {
+	coordinate := func(tag, refTag uint16, negative byte) (float64, bool) {
+		v, ok := x.rationals(x.gps, tag)
+		if !ok || len(v) < 3 {
+			return 0, false
+		}
+		deg := v[0] + v[1]/60 + v[2]/3600
+		if ref, ok := x.gps[refTag]; ok && len(ref.value) > 0 && ref.value[0] == negative {
+			deg = -deg
+		}
+		return deg, true
+	}
+	lat, latOK := coordinate(gpsLatitude, gpsLatitudeRef, 'S')
+	lon, lonOK := coordinate(gpsLongitude, gpsLongitudeRef, 'W')
+	return lat, lon, latOK && lonOK
+}
+
+// Field is a tag formatted for display.
+type Field struct {
+	Name, Value string
+}
+
+// Summary returns the commonly shown tags that are present, formatted for
+// display, in a fixed order.
+func (x *Exif) Summary() []Field {
+	var fields []Field
+	add := func(name, value string) {
+		fields = append(fields, Field{Name: name, Value: value})
+	}
+	str := func(name string, tag uint16) {
+		if s, ok := x.String(tag); ok {
+			add(name, s)
+		}
+	}
+
+	str("Make", TagMake)
+	str("Model", TagModel)
+	str("Lens", TagLensModel)
+	if s, ok := x.String(TagDateTimeOriginal); ok {
+		add("Date taken", s)
+	} else {
+		str("Date", TagDateTime)
+	}
+	if v, ok := x.rationals(x.tags, TagExposureTime); ok {
+		if v[0] > 0 && v[0] < 1 {
+			add("Exposure", fmt.Sprintf("1/%.0fs", 1/v[0]))
+		} else {
+			add("Exposure", strconv.FormatFloat(v[0], 'f', -1, 64)+"s")
+		}
+	}
+	if v, ok := x.rationals(x.tags, TagFNumber); ok {
+		add("Aperture", "f/"+strconv.FormatFloat(v[0], 'f', 1, 64))
+	}
+	if iso, ok := x.Uint(TagISO); ok {
+		add("ISO", strconv.Itoa(int(iso)))
+	}
+	if v, ok := x.rationals(x.tags, TagFocalLength); ok {
+		add("Focal length", strconv.FormatFloat(v[0], 'f', -1, 64)+"mm")
+	}
+	if _, ok := x.Uint(TagOrientation); ok {
+		add("Orientation", strconv.Itoa(x.Orientation()))
+	}
+	str("Software", TagSoftware)
+	str("Artist", TagArtist)
+	str("Copyright", TagCopyright)
+	if lat, lon, ok := x.GPS(); ok {
+		add("GPS", fmt.Sprintf("%.6f, %.6f", lat, lon))
+	} else if x.HasGPS() {
+		add("GPS", "present")
+	}
+	return fields
+}
@@ -0,0 +1,258 @@
+// Package icc reads ICC colour profiles and converts pixels between RGB
+// profiles of the matrix/TRC kind, which is what cameras and design tools
+// embed for Display P3, Adobe RGB and sRGB.
+package icc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf16"
+)
+
+// Tag signatures read by this package.
+const (
+	tagDescription = "desc"
+	tagCopyright   = "cprt"
+	tagWhitePoint  = "wtpt"
+	tagRedXYZ      = "rXYZ"
+	tagGreenXYZ    = "gXYZ"
+	tagBlueXYZ     = "bXYZ"
+	tagRedTRC      = "rTRC"
+	tagGreenTRC    = "gTRC"
+	tagBlueTRC     = "bTRC"
+)
+
+// headerSize is the size of the profile header, which the tag table
+// follows.
+const headerSize = 128
+
+// Profile is a parsed ICC profile.
+type Profile struct {
+	// Description is the profile's name, e.g. "Display P3".
+	Description string
+	// ColorSpace is the colour space of the image data, e.g. "RGB",
+	// "GRAY" or "CMYK".
+	ColorSpace string
+
+	data []byte
+	// matrix converts linear RGB to the XYZ profile connection space; it
+	// is nil unless the profile is an RGB matrix/TRC profile.
+	matrix *[3][3]float64
+	// curves convert the encoded red, green and blue values to linear ones.
+	curves [3]curve
+}
+
+// curve maps an encoded value from 0 to 1 to a linear one.
+type curve func(x float64) float64
+
+// Parse reads an ICC profile.
+func Parse(data []byte) (*Profile, error) {
+	if len(data) < headerSize+4 || string(data[36:40]) != "acsp" {
+		return nil, errors.New("icc: not an ICC profile")
+	}
+	p := &Profile{
+		ColorSpace: strings.TrimSpace(string(data[16:20])),
+		data:       data,
+	}
+
+	tags := make(map[string][]byte)
+	n := int(binary.BigEndian.Uint32(data[headerSize:]))
+	if n > (len(data)-headerSize-4)/12 {
+		return nil, errors.New("icc: truncated tag table")
+	}
+	for i := 0; i < n; i++ {
+		entry := data[headerSize+4+12*i:]
+		offset, size := uint64(binary.BigEndian.Uint32(entry[4:])), uint64(binary.BigEndian.Uint32(entry[8:]))
+		if offset+size > uint64(len(data)) {
+			return nil, fmt.Errorf("icc: tag %q out of range", entry[:4])
+		}
+		tags[string(entry[:4])] = data[offset : offset+size]
+	}
+	p.Description = readText(tags[tagDescription])
+
+	if p.ColorSpace != "RGB" || string(data[20:24]) != "XYZ " {
+		return p, nil
+	}
+	var m [3][3]float64
+	for i, tag := range []string{tagRedXYZ, tagGreenXYZ, tagBlueXYZ} {
+		xyz, ok := readXYZ(tags[tag])
+		if !ok {
+			return p, nil
+		}
+		for row := range m {
+			m[row][i] = xyz[row]
+		}
+	}
+	var curves [3]curve
+	for i, tag := range []string{tagRedTRC, tagGreenTRC, tagBlueTRC} {
+		c, err := readCurve(tags[tag])
+		if err != nil {
+			return nil, err
+		}
+		if c == nil {
+			return p, nil
+		}
+		curves[i] = c
+	}
+	p.matrix, p.curves = &m, curves
+	return p, nil
+}
+
+// Bytes returns the profile as it is embedded in image files.
+func (p *Profile) Bytes() []byte {
+	return p.data
+}
+
+// MatrixShaper reports whether p is an RGB matrix/TRC profile, the kind
+// that Transform converts between.
+func (p *Profile) MatrixShaper() bool {
+	return p.matrix != nil
+}
+
+// Same reports whether p and q are matrix/TRC profiles that describe the
+// same colours, however they are encoded.
+func (p *Profile) Same(q *Profile) bool {
+	if !p.MatrixShaper() || !q.MatrixShaper() {
+		return false
+	}
+	for i := range p.matrix {
+		for j := range p.matrix[i] {
+			if math.Abs(p.matrix[i][j]-q.matrix[i][j]) > 1e-3 {
+				return false
+			}
+		}
+	}
+	for i := range p.curves {
+		for x := 0.0; x <= 1; x += 1.0 / 16 {
+			if math.Abs(p.curves[i](x)-q.curves[i](x)) > 2e-3 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// s15Fixed16 reads a signed fixed point number with 16 fractional bits.
+func s15Fixed16(b []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(b))) / 65536
+}
+
+// readXYZ reads an XYZType tag.
+func readXYZ(b []byte) ([3]float64, bool) {
+	if len(b) < 20 || string(b[:4]) != "XYZ " {
+		return [3]float64{}, false
+	}
+	return [3]float64{s15Fixed16(b[8:]), s15Fixed16(b[12:]), s15Fixed16(b[16:])}, true
+}
+
+// readCurve reads a curveType or parametricCurveType tag. It returns nil
+// for tags of other types.
+func readCurve(b []byte) (curve, error) {
+	if len(b) < 12 {
+		return nil, nil
+	}
+	switch string(b[:4]) {
+	case "curv":
+		n := int(binary.BigEndian.Uint32(b[8:]))
+		if len(b) < 12+2*n {
+			return nil, errors.New("icc: truncated curve")
+		}
+		switch n {
+		case 0:
+			return func(x float64) float64 { return x }, nil
+		case 1:
+			gamma := float64(binary.BigEndian.Uint16(b[12:])) / 256
+			return func(x float64) float64 { return math.Pow(x, gamma) }, nil
+		}
+		table := make([]float64, n)
+		for i := range table {
+			table[i] = float64(binary.BigEndian.Uint16(b[12+2*i:])) / 65535
+		}
+		return func(x float64) float64 {
+			pos := math.Min(math.Max(x, 0), 1) * float64(n-1)
+			i := min(int(pos), n-2)
+			return table[i] + (table[i+1]-table[i])*(pos-float64(i))
+		}, nil
+
+	case "para":
+		kind := binary.BigEndian.Uint16(b[8:])
+		counts := []int{1, 3, 4, 5, 7}
+		if int(kind) >= len(counts) || len(b) < 12+4*counts[kind] {
+			return nil, errors.New("icc: invalid parametric curve")
+		}
+		// The parameters g, a, b, c, d, e and f, as far as present.
+		var v [7]float64
+		for i := 0; i < counts[kind]; i++ {
+			v[i] = s15Fixed16(b[12+4*i:])
+		}
+		g, a, bb, c, d, e, f := v[0], v[1], v[2], v[3], v[4], v[5], v[6]
+		pow := func(x float64) float64 { return math.Pow(math.Max(x, 0), g) }
+		switch kind {
+		case 0:
+			return func(x float64) float64 { return pow(x) }, nil
+		case 1:
+			return func(x float64) float64 {
+				if x >= -bb/a {
+					return pow(a*x + bb)
+				}
+				return 0
+			}, nil
+		case 2:
+			return func(x float64) float64 {
+				if x >= -bb/a {
+					return pow(a*x+bb) + c
+				}
+				return c
+			}, nil
+		case 3:
+			return func(x float64) float64 {
+				if x >= d {
+					return pow(a*x + bb)
+				}
+				return c * x
+			}, nil
+		}
+		return func(x float64) float64 {
+			if x >= d {
+				return pow(a*x+bb) + e
+			}
+			return c*x + f
+		}, nil
+	}
+	return nil, nil
+}
+
+// readText reads a textDescriptionType (version 2), multiLocalizedUnicodeType
+// (version 4) or textType tag, returning the first string.
+func readText(b []byte) string {
+	if len(b) < 12 {
+		return ""
+	}
+	switch string(b[:4]) {
+	case "desc":
+		n := int(binary.BigEndian.Uint32(b[8:]))
+		if len(b) < 12+n {
+			return ""
+		}
+		return strings.TrimRight(string(b[12:12+n]), "\x00")
+	case "mluc":
+		if len(b) < 28 || binary.BigEndian.Uint32(b[8:]) == 0 {
+			return ""
+		}
+		length, offset := int(binary.BigEndian.Uint32(b[20:])), int(binary.BigEndian.Uint32(b[24:]))
+		if offset+length > len(b) {
+			return ""
+		}
+		s := make([]uint16, length/2)
+		for i := range s {
+			s[i] = binary.BigEndian.Uint16(b[offset+2*i:])
+		}
+		return strings.TrimRight(string(utf16.Decode(s)), "\x00")
+	case "text":
+		return strings.TrimRight(string(b[8:]), "\x00")
+	}
+	return ""
+}
@@ -0,0 +1,114 @@
+package icc
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+)
+
+// SRGB is the sRGB profile, which images without an embedded profile are
+// assumed to use. Its data is a compact version 2 profile written by this
+// package.
+var SRGB *Profile
+
+func init() {
+	p, err := Parse(srgbProfile())
+	if err != nil {
+		panic(err)
+	}
+	SRGB = p
+}
+
+// srgbDecode is the sRGB transfer function from encoded to linear values.
+func srgbDecode(x float64) float64 {
+	if x <= 0.04045 {
+		return x / 12.92
+	}
+	return math.Pow((x+0.055)/1.055, 2.4)
+}
+
+// srgbProfile writes the sRGB profile: the sRGB primaries adapted to the
+// D50 white of the profile connection space and the sRGB curve, shared by
+// the three channels, as a table.
+func srgbProfile() []byte {
+	be := binary.BigEndian
+	xyz := func(x, y, z float64) []byte {
+		b := []byte("XYZ \x00\x00\x00\x00")
+		for _, v := range []float64{x, y, z} {
+			b = be.AppendUint32(b, uint32(int32(math.Round(v*65536))))
+		}
+		return b
+	}
+	text := func(s string) []byte {
+		return append([]byte("text\x00\x00\x00\x00"+s), 0)
+	}
+	desc := func(s string) []byte {
+		b := be.AppendUint32([]byte("desc\x00\x00\x00\x00"), uint32(len(s)+1))
+		b = append(b, s...)
+		// The empty Unicode and ScriptCode descriptions.
+		return append(b, make([]byte, 1+4+4+2+1+67)...)
+	}
+	curv := be.AppendUint32([]byte("curv\x00\x00\x00\x00"), 1024)
+	for i := 0; i < 1024; i++ {
+		curv = be.AppendUint16(curv, uint16(math.Round(srgbDecode(float64(i)/1023)*65535)))
+	}
+
+	tags := map[string][]byte{
+		tagDescription: desc("sRGB"),
+		tagCopyright:   text("No copyright, use freely"),
+		tagWhitePoint:  xyz(0.9642, 1, 0.8249),
+		tagRedXYZ:      xyz(0.4360747, 0.2225045, 0.0139322),
+		tagGreenXYZ:    xyz(0.3850649, 0.7168786, 0.0971045),
+		tagBlueXYZ:     xyz(0.1430804, 0.0606169, 0.7141733),
+		tagRedTRC:      curv,
+		tagGreenTRC:    curv,
+		tagBlueTRC:     curv,
+	}
+	return writeProfile(tags)
+}
+
+// writeProfile writes a version 2.1 RGB display profile holding tags. Tags
+// with the same data share it.
+func writeProfile(tags map[string][]byte) []byte {
+	be := binary.BigEndian
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	header := make([]byte, headerSize)
+	be.PutUint32(header[8:], 0x02100000)
+	copy(header[12:], "mntrRGB XYZ ")
+	copy(header[36:], "acsp")
+	// The D50 illuminant of the profile connection space.
+	for i, v := range []float64{0.9642, 1, 0.8249} {
+		be.PutUint32(header[68+4*i:], uint32(int32(math.Round(v*65536))))
+	}
+
+	table := be.AppendUint32(nil, uint32(len(names)))
+	var data []byte
+	offsets := make(map[string]int)
+	dataStart := headerSize + 4 + 12*len(names)
+	for _, name := range names {
+		tag := tags[name]
+		key := string(tag)
+		offset, ok := offsets[key]
+		if !ok {
+			offset = dataStart + len(data)
+			offsets[key] = offset
+			data = append(data, tag...)
+			// Tags start on four byte boundaries.
+			for len(data)%4 != 0 {
+				data = append(data, 0)
+			}
+		}
+		table = append(table, name...)
+		table = be.AppendUint32(table, uint32(offset))
+		table = be.AppendUint32(table, uint32(len(tag)))
+	}
+
+	profile := append(append(header, table...), data...)
+	be.PutUint32(profile, uint32(len(profile)))
+	return profile
+}
@@ -0,0 +1,143 @@
+package icc
+
+import (
+	"errors"
+	"math"
+	"sync"
+)
+
+// encodeSteps is the number of linear values that Transform looks up the
+// encoded output for. It is fine enough for 8-bit output down to the
+// steepest part of common curves.
+const encodeSteps = 4096
+
+// wideSteps is the number of intervals of the encoding table of
+// Convert16, which interpolates in it. The table is indexed by the square
+// root of the linear value, which spends most entries near black, where
+// curves are steepest.
+const wideSteps = 4096
+
+// Transform converts 8-bit or 16-bit RGB values from one matrix/TRC
+// profile to another, with the relative colorimetric intent. Colours
+// outside the target's gamut are clipped.
+type Transform struct {
+	src, dst *Profile
+
+	decode [3][256]float64
+	matrix [3][3]float64
+	encode [3][encodeSteps + 1]uint8
+
+	// The tables of Convert16 are only built for 16-bit images.
+	wide     sync.Once
+	decode16 [3][]float64
+	encode16 [3][wideSteps + 1]float64
+}
+
+// NewTransform returns the transform from src to dst.
+func NewTransform(src, dst *Profile) (*Transform, error) {
+	if !src.MatrixShaper() || !dst.MatrixShaper() {
+		return nil, errors.New("icc: only RGB matrix/TRC profiles can be converted")
+	}
+	inverse, ok := invert(dst.matrix)
+	if !ok {
+		return nil, errors.New("icc: the output profile's matrix is singular")
+	}
+
+	t := &Transform{src: src, dst: dst, matrix: multiply(inverse, src.matrix)}
+	for c := range t.decode {
+		for v := range t.decode[c] {
+			t.decode[c][v] = src.curves[c](float64(v) / 255)
+		}
+		for i := range t.encode[c] {
+			t.encode[c][i] = uint8(math.Round(inverseCurve(dst.curves[c], float64(i)/encodeSteps) * 255))
+		}
+	}
+	return t, nil
+}
+
+// Convert converts one pixel.
+func (t *Transform) Convert(r, g, b uint8) (uint8, uint8, uint8) {
+	lr, lg, lb := t.decode[0][r], t.decode[1][g], t.decode[2][b]
+	var out [3]uint8
+	for c, row := range t.matrix {
+		v := row[0]*lr + row[1]*lg + row[2]*lb
+		v = math.Min(math.Max(v, 0), 1)
+		out[c] = t.encode[c][int(v*encodeSteps+0.5)]
+	}
+	return out[0], out[1], out[2]
+}
+
+// Convert16 converts one pixel of a 16-bit image.
+func (t *Transform) Convert16(r, g, b uint16) (uint16, uint16, uint16) {
+	t.wide.Do(t.buildWide)
+	lr, lg, lb := t.decode16[0][r], t.decode16[1][g], t.decode16[2][b]
+	var out [3]uint16
+	for c, row := range t.matrix {
+		v := row[0]*lr + row[1]*lg + row[2]*lb
+		pos := math.Sqrt(math.Min(math.Max(v, 0), 1)) * wideSteps
+		i := min(int(pos), wideSteps-1)
+		e := &t.encode16[c]
+		out[c] = uint16(math.Round((e[i] + (e[i+1]-e[i])*(pos-float64(i))) * 65535))
+	}
+	return out[0], out[1], out[2]
+}
+
+// buildWide builds the tables of Convert16.
+func (t *Transform) buildWide() {
+	for c := range t.decode16 {
+		t.decode16[c] = make([]float64, 1<<16)
+		for v := range t.decode16[c] {
+			t.decode16[c][v] = t.src.curves[c](float64(v) / 65535)
+		}
+		for i := range t.encode16[c] {
+			s := float64(i) / wideSteps
+			t.encode16[c][i] = inverseCurve(t.dst.curves[c], s*s)
+		}
+	}
+}
+
+// inverseCurve finds the encoded value that c maps to y, by bisection, as
+// curves are increasing.
+func inverseCurve(c curve, y float64) float64 {
+	lo, hi := 0.0, 1.0
+	for i := 0; i < 32; i++ {
+		mid := (lo + hi) / 2
+		if c(mid) < y {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2
+}
+
+func multiply(a, b *[3][3]float64) [3][3]float64 {
+	var m [3][3]float64
+	for i := range m {
+		for j := range m[i] {
+			for k := 0; k < 3; k++ {
+				m[i][j] += a[i][k] * b[k][j]
+			}
+		}
+	}
+	return m
+}
+
+func invert(m *[3][3]float64) (*[3][3]float64, bool) {
+	det := m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+	if math.Abs(det) < 1e-12 {
+		return nil, false
+	}
+	var inv [3][3]float64
+	for i := range inv {
+		for j := range inv[i] {
+			// The cofactor of m[j][i], from the rows and columns other than j and i.
+			r0, r1 := (j+1)%3, (j+2)%3
+			c0, c1 := (i+1)%3, (i+2)%3
+			inv[i][j] = (m[r0][c0]*m[r1][c1] - m[r0][c1]*m[r1][c0]) / det
+		}
+	}
+	return &inv, true
+}
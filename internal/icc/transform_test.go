@@ -0,0 +1,80 @@
+package icc
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// displayP3 returns a Display P3 profile: the P3 primaries adapted to D50
+// and the sRGB curve.
+func displayP3(t *testing.T) *Profile {
+	t.Helper()
+	be := binary.BigEndian
+	xyz := func(x, y, z float64) []byte {
+		b := []byte("XYZ \x00\x00\x00\x00")
+		for _, v := range []float64{x, y, z} {
+			b = be.AppendUint32(b, uint32(int32(math.Round(v*65536))))
+		}
+		return b
+	}
+	curv := be.AppendUint32([]byte("curv\x00\x00\x00\x00"), 1024)
+	for i := 0; i < 1024; i++ {
+		curv = be.AppendUint16(curv, uint16(math.Round(srgbDecode(float64(i)/1023)*65535)))
+	}
+	p, err := Parse(writeProfile(map[string][]byte{
+		tagWhitePoint: xyz(0.9642, 1, 0.8249),
+		tagRedXYZ:     xyz(0.5151, 0.2412, -0.0011),
+		tagGreenXYZ:   xyz(0.2920, 0.6922, 0.0419),
+		tagBlueXYZ:    xyz(0.1571, 0.0666, 0.7841),
+		tagRedTRC:     curv,
+		tagGreenTRC:   curv,
+		tagBlueTRC:    curv,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestConvert16Identity(t *testing.T) {
+	tr, err := NewTransform(SRGB, SRGB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for v := 0; v < 1<<16; v++ {
+		r, g, b := tr.Convert16(uint16(v), uint16(v), uint16(v))
+		for _, got := range []uint16{r, g, b} {
+			if d := int(got) - v; d < -1 || d > 1 {
+				t.Fatalf("Convert16(%d) = %d, off by %d", v, got, d)
+			}
+		}
+	}
+}
+
+// TestConvert16MatchesConvert checks that 16-bit conversions agree with
+// 8-bit ones and keep more precision than 8 bits.
+func TestConvert16MatchesConvert(t *testing.T) {
+	tr, err := NewTransform(displayP3(t), SRGB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	distinct := make(map[uint16]bool)
+	for v := 0; v < 256; v++ {
+		r8, g8, b8 := tr.Convert(uint8(v), uint8(255-v), uint8(v/2))
+		r, g, b := tr.Convert16(uint16(v)*257, uint16(255-v)*257, uint16(v/2)*257)
+		for i, pair := range [][2]int{{int(r8), int(r)}, {int(g8), int(g)}, {int(b8), int(b)}} {
+			if d := pair[0]*257 - pair[1]; d < -257 || d > 257 {
+				t.Errorf("pixel %d channel %d: Convert gives %d, Convert16 %d", v, i, pair[0], pair[1])
+			}
+		}
+		// Neighbouring 16-bit inputs between two 8-bit ones.
+		for step := 0; step < 257 && v < 255; step += 32 {
+			r, _, _ := tr.Convert16(uint16(v*257+step), 0, 0)
+			distinct[r] = true
+		}
+	}
+	if len(distinct) <= 256 {
+		t.Errorf("16-bit conversions give %d distinct values, no more than 8-bit ones", len(distinct))
+	}
+}
@@ -1,12 +1,12 @@
 package tui
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"io"
 	"io/fs"
-	"net/http"
-	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -26,6 +26,9 @@ type fileInfo struct {
 	path     string
 	mimeType string
 	ext      string // extension (with leading dot)
+	// mismatch is set if the file extension does not match the content,
+	// which takes precedence.
+	mismatch *detect.Mismatch
 	err      error
 }
 
@@ -33,6 +36,7 @@ type fileInfoMsg fileInfo
 
 type convertDoneMsg struct {
 	outputPath string
+	warnings   []string
 	err        error
 }
 
@@ -46,10 +50,14 @@ type model struct {
 	spinner     spinner.Model
 	processing  bool // while detecting mime type
 	converting  bool // while actual conversion happens
+	cancel      context.CancelFunc
+	cancelling  bool // Esc was pressed during the conversion
+	quitting    bool // Ctrl+C was pressed during the conversion
 	file        fileInfo
 	choices     []string // destination extensions (with dot)
 	cursor      int
-	choice      string // chosen destination extension
+	choice      string   // chosen destination extension
+	route       []string // formats the chosen conversion passes through
 	width       int
 	height      int
 	input       textinput.Model
@@ -57,12 +65,19 @@ type model struct {
 	selIdx      int
 	searching   bool
 
+	// Converter options form, shown after choosing a destination
+	configuring bool
+	options     optionsForm
+	// policy is the conflict policy the form starts with.
+	policy converter.ConflictPolicy
+
 	// Post-conversion feedback
 	showSuccess bool
 	successPath string
+	warnings    []string
 }
 
-func initialModel() model {
+func initialModel(policy converter.ConflictPolicy) model {
 	sp := spinner.New()
 	sp.Spinner = spinner.Dot
 	sp.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("63"))
@@ -77,54 +92,73 @@ func initialModel() model {
 		input:       ti,
 		suggestions: searchFiles(""),
 		searching:   true,
+		policy:      policy,
 	}
 }
 
 // checkFileCmd inspects the dropped file and returns a fileInfoMsg.
 func checkFileCmd(path string) tea.Cmd {
 	return func() tea.Msg {
-		file, err := os.Open(path)
+		match, err := detect.DetectFile(path)
 		if err != nil {
-			return fileInfoMsg{path: path, err: fmt.Errorf("could not open file: %w", err)}
-		}
-		defer file.Close()
-
-		buffer := make([]byte, 512)
-		n, err := file.Read(buffer)
-		if err != nil && err != io.EOF {
 			return fileInfoMsg{path: path, err: fmt.Errorf("could not read file: %w", err)}
 		}
 
-		mimeType := http.DetectContentType(buffer[:n])
-
-		ext, ok := detect.ExtensionFromMimeType(mimeType)
-		if !ok {
-			ext = strings.ToLower(filepath.Ext(path))
+		return fileInfoMsg{
+			path:     path,
+			mimeType: match.MimeType,
+			ext:      sourceFormat(path, match),
+			mismatch: detect.CheckExtension(path, match),
 		}
-		ext = alias.Resolve(ext)
+	}
+}
 
-		return fileInfoMsg{path: path, mimeType: mimeType, ext: ext, err: nil}
+// sourceFormat returns the format of the file at path: the detected one,
+// or the one named by the extension if the content was not recognised.
+func sourceFormat(path string, match detect.Match) string {
+	ext := match.Ext
+	if ext == "" {
+		ext = strings.ToLower(filepath.Ext(path))
 	}
+	return alias.Resolve(ext)
 }
 
 // convertCmd executes the conversion and returns a convertDoneMsg.
-func convertCmd(srcPath, fromExt, toExt string) tea.Cmd {
+func convertCmd(ctx context.Context, srcPath, fromExt, toExt string, options converter.Options, policy converter.ConflictPolicy) tea.Cmd {
 	return func() tea.Msg {
 		conv, ok := converter.GetConverter(fromExt, toExt)
 		if !ok {
-			return convertDoneMsg{err: fmt.Errorf("no converter from %s to %s", fromExt, toExt)}
+			return convertDoneMsg{err: fmt.Errorf("%w from %s to %s", converter.ErrNoConverter, fromExt, toExt)}
 		}
 
-		base := strings.TrimSuffix(srcPath, filepath.Ext(srcPath))
-		outputPath := base + toExt
+		outputs := &converter.Outputs{Policy: policy}
+		outputPath, err := outputs.Resolve(srcPath, converter.DefaultOutputPath(srcPath, toExt))
+		if err != nil {
+			return convertDoneMsg{err: err}
+		}
 
-		if err := conv.Convert(srcPath, outputPath, nil); err != nil {
+		// Collect warnings instead of letting them print over the UI.
+		var warnings []string
+		options = options.WithWarningFunc(func(msg string) {
+			warnings = append(warnings, msg)
+		})
+
+		if err := conv.Convert(ctx, srcPath, outputPath, options); err != nil {
 			return convertDoneMsg{err: err}
 		}
-		return convertDoneMsg{outputPath: outputPath}
+		return convertDoneMsg{outputPath: outputPath, warnings: warnings}
 	}
 }
 
+// startConversion starts converting the selected file to m.choice. The
+// conversion can be cancelled with m.cancel until it is done.
+func (m *model) startConversion(options converter.Options, policy converter.ConflictPolicy) tea.Cmd {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.converting = true
+	m.cancel = cancel
+	return convertCmd(ctx, m.file.path, m.file.ext, m.choice, options, policy)
+}
+
 // Init implements tea.Model.
 func (m model) Init() tea.Cmd {
 	return tea.Batch(tea.EnableBracketedPaste, m.spinner.Tick)
@@ -138,6 +172,24 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case tea.KeyMsg:
+		if m.configuring {
+			return m.handleOptionKeys(msg)
+		}
+
+		// Cancelling lets the converter remove its partial output; the
+		// program only quits once the convertDoneMsg has arrived.
+		if m.converting {
+			switch msg.String() {
+			case "esc":
+				m.cancel()
+				m.cancelling = true
+			case "ctrl+c":
+				m.cancel()
+				m.quitting = true
+			}
+			return m, nil
+		}
+
 		if msg.Type == tea.KeyRunes {
 			maybePath := sanitizeDroppedPath(string(msg.Runes))
 			if filepath.IsAbs(maybePath) {
@@ -218,6 +270,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			for dest := range convMap {
 				m.choices = append(m.choices, dest)
 			}
+			sort.Strings(m.choices)
 			if len(m.choices) == 0 {
 				m.choices = []string{} // no converters, keep empty
 			} else {
@@ -228,11 +281,20 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case convertDoneMsg:
 		m.converting = false
-		if msg.err != nil {
+		m.cancel()
+		m.cancel = nil
+		m.cancelling = false
+		if m.quitting {
+			return m, tea.Quit
+		}
+		if errors.Is(msg.err, context.Canceled) {
+			m.file.err = errors.New("conversion cancelled")
+		} else if msg.err != nil {
 			m.file.err = msg.err
 		} else {
 			m.showSuccess = true
 			m.successPath = msg.outputPath
+			m.warnings = msg.warnings
 			m.file.err = nil
 		}
 
@@ -246,6 +308,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case resetMsg:
 		// Reset state to initial search screen
 		m.showSuccess = false
+		m.warnings = nil
 		m.file.err = nil
 		m.searching = true
 		m.input.Reset()
@@ -253,6 +316,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.selIdx = 0
 		m.file = fileInfo{}
 		m.choice = ""
+		m.route = nil
 		m.choices = nil
 		return m, nil
 
@@ -276,8 +340,16 @@ func (m *model) handleChoiceKeys(key tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 	case "enter":
 		m.choice = m.choices[m.cursor]
-		m.converting = true
-		return *m, convertCmd(m.file.path, m.file.ext, m.choice)
+		m.route = []string{m.file.ext, m.choice}
+		if conv, ok := converter.GetConverter(m.file.ext, m.choice); ok {
+			m.route = converter.Route(conv)
+			if flags := conv.GetFlags(); flags.HasFlags() {
+				m.options = newOptionsForm(conv.GetFlags, m.policy)
+				m.configuring = true
+				return *m, textinput.Blink
+			}
+		}
+		return *m, m.startConversion(nil, m.policy)
 	case "ctrl+c", "esc", "q":
 		return *m, tea.Quit
 	}
@@ -290,14 +362,26 @@ func (m model) View() string {
 
 	if m.processing {
 		content = fmt.Sprintf("%s Processing %s…", m.spinner.View(), m.file.path)
+	} else if m.converting && m.cancelling {
+		content = fmt.Sprintf("%s Cancelling…", m.spinner.View())
 	} else if m.converting {
-		content = fmt.Sprintf("%s Converting %s → %s…", m.spinner.View(), m.file.ext, m.choice)
+		content = fmt.Sprintf("%s Converting %s…", m.spinner.View(), strings.Join(m.route, " → "))
+		content += "\n\n" + m.styles.Help.Render("(Esc to cancel)")
+	} else if m.configuring {
+		content = m.styles.InfoBox.Render(m.options.View(m.styles, m.choice))
 	} else if m.showSuccess {
-		content = m.styles.InfoBox.Render(m.styles.Success.Render(fmt.Sprintf("✓ Converted to %s", m.successPath)))
+		lines := []string{m.styles.Success.Render(fmt.Sprintf("✓ Converted to %s", m.successPath))}
+		for _, w := range m.warnings {
+			lines = append(lines, m.styles.Help.Render("! "+w))
+		}
+		content = m.styles.InfoBox.Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
 	} else if m.file.err != nil && !m.searching {
 		content = m.styles.ErrorBox.Render(m.styles.Error.Render(fmt.Sprintf("Error: %v", m.file.err)))
 	} else if len(m.choices) > 0 {
 		var sb strings.Builder
+		if m.file.mismatch != nil {
+			sb.WriteString(m.styles.Help.Render(fmt.Sprintf("! %v, converting it as %s", m.file.mismatch, strings.TrimPrefix(m.file.ext, "."))) + "\n\n")
+		}
 		sb.WriteString("What format would you like to convert to?\n\n")
 		for i, c := range m.choices {
 			cursor := " "
@@ -353,9 +437,11 @@ func (m model) View() string {
 	return m.styles.App.Render(lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, ui))
 }
 
-// Run launches the interactive TUI. Exposed to CLI package.
-func Run() error {
-	p := tea.NewProgram(initialModel(), tea.WithAltScreen())
+// Run launches the interactive TUI. Exposed to CLI package. Existing
+// output files are handled according to policy unless the user picks
+// another policy in the options form.
+func Run(policy converter.ConflictPolicy) error {
+	p := tea.NewProgram(initialModel(policy), tea.WithAltScreen())
 	_, err := p.Run()
 	return err
 }
@@ -376,9 +462,13 @@ func searchFiles(query string) []string {
 			return nil
 		}
 
-		ext := strings.ToLower(filepath.Ext(path))
-		ext = alias.Resolve(ext)
-		if convs := converter.GetConvertersFor(ext); len(convs) == 0 {
+		// Detect the type as checkFileCmd will, so that files whose
+		// extension is wrong or missing are offered too.
+		match, err := detect.DetectFile(path)
+		if err != nil {
+			return nil
+		}
+		if convs := converter.GetConvertersFor(sourceFormat(path, match)); len(convs) == 0 {
 			return nil // unsupported mime/extension
 		}
 
@@ -0,0 +1,160 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/renja-g/convert/internal/converter"
+	"github.com/spf13/pflag"
+)
+
+// optionsForm lets the user edit the flags of the selected converter
+// before the conversion starts. Every flag gets a text input that is
+// prefilled with the flag's default value. An extra field chooses what
+// happens if the output file exists.
+type optionsForm struct {
+	// newFlags returns a fresh flag set of the converter, so that every
+	// submit parses the entered values from the defaults.
+	newFlags func() *pflag.FlagSet
+	flags    *pflag.FlagSet
+	names    []string
+	inputs   []textinput.Model
+	cursor   int
+	err      error
+}
+
+// conflictFlag is the form field that selects the conflict policy.
+const conflictFlag = "on-conflict"
+
+// conflictPolicies are the values of the conflict field. Without a way to
+// ask before replacing a file, skipping an existing output is the same as
+// failing.
+var conflictPolicies = map[string]converter.ConflictPolicy{
+	"suffix":    converter.SuffixOnConflict,
+	"overwrite": converter.Overwrite,
+	"fail":      converter.FailOnConflict,
+}
+
+// policyName returns the conflict field's value for policy.
+func policyName(policy converter.ConflictPolicy) string {
+	switch policy {
+	case converter.SuffixOnConflict:
+		return "suffix"
+	case converter.Overwrite:
+		return "overwrite"
+	}
+	return "fail"
+}
+
+// newOptionsForm returns a form for the flags returned by newFlags, whose
+// conflict field starts at policy.
+func newOptionsForm(newFlags func() *pflag.FlagSet, policy converter.ConflictPolicy) optionsForm {
+	withConflict := func() *pflag.FlagSet {
+		flags := newFlags()
+		flags.String(conflictFlag, policyName(policy), "If the output file exists: suffix (write to a free name), overwrite or fail")
+		return flags
+	}
+	f := optionsForm{newFlags: withConflict, flags: withConflict()}
+	f.flags.VisitAll(func(flag *pflag.Flag) {
+		ti := textinput.New()
+		ti.Prompt = ""
+		ti.SetValue(defaultValue(flag))
+		f.names = append(f.names, flag.Name)
+		f.inputs = append(f.inputs, ti)
+	})
+	f.inputs[0].Focus()
+	return f
+}
+
+// focus moves the cursor to input i.
+func (f *optionsForm) focus(i int) {
+	f.inputs[f.cursor].Blur()
+	f.cursor = i
+	f.inputs[f.cursor].Focus()
+}
+
+// defaultValue returns the text an input starts with. Slice flags print
+// their empty default as "[]", which Set would take for an element.
+func defaultValue(flag *pflag.Flag) string {
+	if strings.HasSuffix(flag.Value.Type(), "Slice") && flag.DefValue == "[]" {
+		return ""
+	}
+	return flag.DefValue
+}
+
+// apply parses the edited values into a fresh flag set and returns the
+// resulting conversion options and conflict policy. Inputs left at their
+// default are not parsed, so the flags keep their defaults.
+func (f *optionsForm) apply() (converter.Options, converter.ConflictPolicy, error) {
+	flags := f.newFlags()
+	for i, name := range f.names {
+		value := strings.TrimSpace(f.inputs[i].Value())
+		if value == defaultValue(flags.Lookup(name)) {
+			continue
+		}
+		if err := flags.Set(name, value); err != nil {
+			return nil, 0, fmt.Errorf("invalid value for %s: %w", name, err)
+		}
+	}
+
+	name := flags.Lookup(conflictFlag).Value.String()
+	policy, ok := conflictPolicies[name]
+	if !ok {
+		return nil, 0, fmt.Errorf("invalid value for %s: %q (want suffix, overwrite or fail)", conflictFlag, name)
+	}
+	options := converter.OptionsFromFlags(flags)
+	delete(options, conflictFlag)
+	return options, policy, nil
+}
+
+// View renders the form.
+func (f optionsForm) View(s styles, dest string) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Options for %s:\n\n", strings.TrimPrefix(dest, ".")))
+	for i, name := range f.names {
+		cursor := " "
+		label := name
+		if f.cursor == i {
+			cursor = ">"
+			label = s.Choice.Render(label)
+		}
+		usage := s.Help.Render(f.flags.Lookup(name).Usage)
+		sb.WriteString(fmt.Sprintf("%s %s: %s  %s\n", cursor, label, f.inputs[i].View(), usage))
+	}
+	if f.err != nil {
+		sb.WriteString("\n" + s.Error.Render(f.err.Error()) + "\n")
+	}
+	sb.WriteString("\n" + s.Help.Render("(Tab/↑/↓ to move, Enter to convert, Esc to go back)"))
+	return sb.String()
+}
+
+func (m *model) handleOptionKeys(key tea.KeyMsg) (tea.Model, tea.Cmd) {
+	f := &m.options
+	switch key.String() {
+	case "up", "shift+tab":
+		f.focus((f.cursor + len(f.inputs) - 1) % len(f.inputs))
+		return *m, nil
+	case "down", "tab":
+		f.focus((f.cursor + 1) % len(f.inputs))
+		return *m, nil
+	case "enter":
+		options, policy, err := f.apply()
+		if err != nil {
+			f.err = err
+			return *m, nil
+		}
+		m.configuring = false
+		return *m, m.startConversion(options, policy)
+	case "esc":
+		m.configuring = false
+		return *m, nil
+	case "ctrl+c":
+		return *m, tea.Quit
+	}
+
+	var cmd tea.Cmd
+	f.inputs[f.cursor], cmd = f.inputs[f.cursor].Update(key)
+	return *m, cmd
+}
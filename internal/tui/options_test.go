@@ -0,0 +1,58 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/renja-g/convert/internal/converter"
+	"github.com/spf13/pflag"
+)
+
+func testFlags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.Int("quality", 75, "Quality")
+	return flags
+}
+
+// set types value into the form field for the flag name.
+func (f *optionsForm) set(t *testing.T, name, value string) {
+	t.Helper()
+	for i, n := range f.names {
+		if n == name {
+			f.inputs[i].SetValue(value)
+			return
+		}
+	}
+	t.Fatalf("the form has no field %s", name)
+}
+
+func TestOptionsFormConflictPolicy(t *testing.T) {
+	for _, policy := range []converter.ConflictPolicy{converter.SuffixOnConflict, converter.Overwrite, converter.FailOnConflict} {
+		f := newOptionsForm(testFlags, policy)
+		options, got, err := f.apply()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != policy {
+			t.Errorf("form started with policy %d returned %d", policy, got)
+		}
+		if _, ok := options[conflictFlag]; ok {
+			t.Errorf("options hold the %s field", conflictFlag)
+		}
+	}
+
+	f := newOptionsForm(testFlags, converter.SuffixOnConflict)
+	f.set(t, conflictFlag, "overwrite")
+	f.set(t, "quality", "90")
+	options, policy, err := f.apply()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy != converter.Overwrite || options.Int("quality", 0) != 90 {
+		t.Errorf("apply = %v, %d, want quality 90 and Overwrite", options, policy)
+	}
+
+	f.set(t, conflictFlag, "ask")
+	if _, _, err := f.apply(); err == nil {
+		t.Error("apply accepted an unknown conflict policy")
+	}
+}